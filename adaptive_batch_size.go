@@ -0,0 +1,76 @@
+package dataloader
+
+import (
+	"math"
+	"time"
+)
+
+// AdaptiveBatchSize lets a QueryBatcher shrink its batches when large ones make the getter slow, and grow them back (up to maxBatchSize) while it keeps up.
+// After each full batch that the getter returns within TargetLatency, the effective batch size grows by Increase; after each batch that takes longer, it is multiplied by DecreaseFactor (AIMD).
+type AdaptiveBatchSize struct {
+	TargetLatency  time.Duration
+	MinBatchSize   int     // defaults to 1
+	Increase       int     // defaults to a twentieth of the range between MinBatchSize and maxBatchSize
+	DecreaseFactor float64 // must be between 0 and 1, defaults to 0.5
+}
+
+// batchSizeController picks the size of the next batch, which is maxBatchSize unless AdaptiveBatchSize is set
+type batchSizeController struct {
+	adaptive bool
+	options  AdaptiveBatchSize
+	max      int
+	size     int
+}
+
+func newBatchSizeController(adaptive *AdaptiveBatchSize, maxBatchSize int) *batchSizeController {
+	if adaptive == nil {
+		return &batchSizeController{max: maxBatchSize, size: maxBatchSize}
+	}
+	options := *adaptive
+	if options.MinBatchSize <= 0 {
+		options.MinBatchSize = 1
+	}
+	if options.MinBatchSize > maxBatchSize {
+		options.MinBatchSize = maxBatchSize
+	}
+	if options.Increase <= 0 {
+		options.Increase = (maxBatchSize - options.MinBatchSize) / 20
+		if options.Increase < 1 {
+			options.Increase = 1
+		}
+	}
+	if options.DecreaseFactor <= 0 || options.DecreaseFactor >= 1 {
+		options.DecreaseFactor = 0.5
+	}
+	return &batchSizeController{
+		adaptive: true,
+		options:  options,
+		max:      maxBatchSize,
+		size:     maxBatchSize, // start out behaving like a fixed maxBatchSize until latency says otherwise
+	}
+}
+
+func (controller *batchSizeController) current() int {
+	return controller.size
+}
+
+// observe adjusts the batch size given how long the getter took to respond to a batch of the given size
+func (controller *batchSizeController) observe(batchSize int, latency time.Duration) {
+	if !controller.adaptive {
+		return
+	}
+	if latency > controller.options.TargetLatency {
+		decreased := int(math.Floor(float64(controller.size) * controller.options.DecreaseFactor))
+		if decreased < controller.options.MinBatchSize {
+			decreased = controller.options.MinBatchSize
+		}
+		controller.size = decreased
+	} else if batchSize >= controller.size {
+		// batches that weren't full say nothing about how larger ones would perform
+		increased := controller.size + controller.options.Increase
+		if increased > controller.max {
+			increased = controller.max
+		}
+		controller.size = increased
+	}
+}
@@ -0,0 +1,116 @@
+package dataloader
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/preston-wagner/unicycle/promises"
+)
+
+// simulatedLatency stands in for a database that slows down linearly with the number of keys in a query
+func simulatedLatency(batchSize int) time.Duration {
+	return time.Duration(batchSize) * time.Millisecond
+}
+
+func TestBatchSizeControllerConverges(t *testing.T) {
+	controller := newBatchSizeController(&AdaptiveBatchSize{
+		TargetLatency: 40 * time.Millisecond,
+		MinBatchSize:  5,
+	}, 100)
+	if controller.current() != 100 {
+		t.Fatal("batchSizeController did not start at maxBatchSize, got", controller.current())
+	}
+	for i := 0; i < 200; i++ {
+		size := controller.current()
+		controller.observe(size, simulatedLatency(size))
+		if i < 100 {
+			continue
+		}
+		// once converged, it should hover just around the size that meets the target
+		if controller.current() < 20 || controller.current() > 44 {
+			t.Fatal("batchSizeController did not converge on the target latency, got", controller.current())
+		}
+	}
+}
+
+func TestBatchSizeControllerBounds(t *testing.T) {
+	controller := newBatchSizeController(&AdaptiveBatchSize{
+		TargetLatency: time.Millisecond,
+		MinBatchSize:  5,
+	}, 100)
+	for i := 0; i < 20; i++ {
+		controller.observe(controller.current(), time.Second)
+	}
+	if controller.current() != 5 {
+		t.Fatal("batchSizeController did not stop shrinking at MinBatchSize, got", controller.current())
+	}
+	for i := 0; i < 100; i++ {
+		controller.observe(controller.current(), 0)
+	}
+	if controller.current() != 100 {
+		t.Fatal("batchSizeController did not stop growing at maxBatchSize, got", controller.current())
+	}
+
+	controller.observe(controller.current(), time.Second)
+	shrunk := controller.current()
+	controller.observe(1, 0)
+	if controller.current() != shrunk {
+		t.Fatal("batchSizeController grew after a batch that wasn't full, got", controller.current())
+	}
+
+	fixed := newBatchSizeController(nil, 100)
+	fixed.observe(100, time.Hour)
+	if fixed.current() != 100 {
+		t.Fatal("batchSizeController changed size without AdaptiveBatchSize, got", fixed.current())
+	}
+}
+
+func TestQueryBatcherAdaptiveBatchSize(t *testing.T) {
+	var sizes []int
+	lock := &sync.Mutex{}
+	getter := func(keys []int) (map[int]int, map[int]error) {
+		lock.Lock()
+		sizes = append(sizes, len(keys))
+		lock.Unlock()
+		time.Sleep(simulatedLatency(len(keys)))
+		result := map[int]int{}
+		for _, key := range keys {
+			result[key] = key
+		}
+		return result, nil
+	}
+
+	batcher := NewQueryBatcherWithOptions(getter, 1, 100, Options[int]{
+		AdaptiveBatchSize: &AdaptiveBatchSize{TargetLatency: 20 * time.Millisecond},
+	})
+	defer batcher.Close()
+	if batcher.Stats().BatchSize != 100 {
+		t.Fatal("QueryBatcher did not report maxBatchSize before any batches, got", batcher.Stats().BatchSize)
+	}
+
+	loads := []*promises.Promise[int]{}
+	for i := 0; i < 400; i++ {
+		loads = append(loads, batcher.LoadPromise(i))
+	}
+	for _, result := range promises.AwaitAll(loads...) {
+		if result.Err != nil {
+			t.Fatal("QueryBatcher did not load every key", result.Err)
+		}
+	}
+
+	if batcher.Stats().BatchSize >= 100 {
+		t.Fatal("QueryBatcher did not shrink its batches when the getter was slow, got", batcher.Stats().BatchSize)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	large := 0
+	for _, size := range sizes {
+		if size > 50 {
+			large++
+		}
+	}
+	if large > 1 {
+		t.Fatal("QueryBatcher did not halve its batches after a slow one, got", sizes)
+	}
+}
@@ -1,26 +1,50 @@
 package dataloader
 
 import (
+	"context"
+
 	"github.com/preston-wagner/unicycle/defaults"
 	"github.com/preston-wagner/unicycle/promises"
 )
 
-type query[KEY_TYPE comparable, VALUE_TYPE any] struct {
-	key     KEY_TYPE
-	promise *promises.Promise[VALUE_TYPE]
+type query[KEY_TYPE any, CACHE_KEY comparable, VALUE_TYPE any] struct {
+	ctx       context.Context
+	key       KEY_TYPE
+	cacheKey  CACHE_KEY
+	priority  Priority
+	tenant    string
+	partition string
+	cost      int
+	promise   *promises.Promise[VALUE_TYPE]
+}
+
+// the original key is kept alongside its waiting promises so that the getter receives the value callers passed in, not its cache key
+type batchEntry[KEY_TYPE any, VALUE_TYPE any] struct {
+	key      KEY_TYPE
+	tenant   string
+	promises []*promises.Promise[VALUE_TYPE]
 }
 
-type batch[KEY_TYPE comparable, VALUE_TYPE any] map[KEY_TYPE][]*promises.Promise[VALUE_TYPE]
+type batch[KEY_TYPE any, CACHE_KEY comparable, VALUE_TYPE any] map[CACHE_KEY]*batchEntry[KEY_TYPE, VALUE_TYPE]
+
+func (btch batch[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) keys() []KEY_TYPE {
+	keys := make([]KEY_TYPE, 0, len(btch))
+	for _, entry := range btch {
+		keys = append(keys, entry.key)
+	}
+	return keys
+}
 
-func (btch batch[KEY_TYPE, VALUE_TYPE]) addToBatch(incomingQuery query[KEY_TYPE, VALUE_TYPE]) {
-	_, ok := btch[incomingQuery.key]
-	if !ok {
-		btch[incomingQuery.key] = []*promises.Promise[VALUE_TYPE]{}
+// promiseCount returns how many callers are waiting on the batch, which may be more than its number of keys
+func (btch batch[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) promiseCount() int {
+	count := 0
+	for _, entry := range btch {
+		count += len(entry.promises)
 	}
-	btch[incomingQuery.key] = append(btch[incomingQuery.key], incomingQuery.promise)
+	return count
 }
 
-func (btch batch[KEY_TYPE, VALUE_TYPE]) resolveAll(values map[KEY_TYPE]VALUE_TYPE, errs map[KEY_TYPE]error) {
+func (btch batch[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) resolveAll(values map[CACHE_KEY]VALUE_TYPE, errs map[CACHE_KEY]error) {
 	for key := range btch {
 		if value, ok := values[key]; ok {
 			btch.resolveKey(key, value)
@@ -32,19 +56,19 @@ func (btch batch[KEY_TYPE, VALUE_TYPE]) resolveAll(values map[KEY_TYPE]VALUE_TYP
 	}
 }
 
-func (btch batch[KEY_TYPE, VALUE_TYPE]) resolveKey(key KEY_TYPE, value VALUE_TYPE) {
-	for _, promise := range btch[key] {
+func (btch batch[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) resolveKey(key CACHE_KEY, value VALUE_TYPE) {
+	for _, promise := range btch[key].promises {
 		promise.Resolve(value, nil)
 	}
 }
 
-func (btch batch[KEY_TYPE, VALUE_TYPE]) rejectKey(key KEY_TYPE, err error) {
-	for _, promise := range btch[key] {
+func (btch batch[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) rejectKey(key CACHE_KEY, err error) {
+	for _, promise := range btch[key].promises {
 		promise.Resolve(defaults.ZeroValue[VALUE_TYPE](), err)
 	}
 }
 
-func (btch batch[KEY_TYPE, VALUE_TYPE]) rejectAll(err error) {
+func (btch batch[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) rejectAll(err error) {
 	for key := range btch {
 		btch.rejectKey(key, err)
 	}
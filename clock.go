@@ -0,0 +1,48 @@
+package dataloader
+
+import "time"
+
+// Clock is the source of time for everything time-dependent in this package (getter latency, rate limits, hedging delays), so that tests can substitute a fake clock such as dataloadertest.FakeClock
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is the subset of *time.Timer returned by Clock.NewTimer
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// SystemClock is the Clock used when none is configured, backed by the time package
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+type systemTimer struct {
+	*time.Timer
+}
+
+func (timer systemTimer) C() <-chan time.Time {
+	return timer.Timer.C
+}
+
+func clockOrDefault(clock Clock) Clock {
+	if clock == nil {
+		return SystemClock
+	}
+	return clock
+}
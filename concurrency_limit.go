@@ -0,0 +1,108 @@
+package dataloader
+
+import (
+	"math"
+	"time"
+)
+
+// AdaptiveConcurrency lets a QueryBatcher vary how many getter calls it runs at once, up to maxConcurrentBatches, based on how their latency changes, similar to the Vegas limiter in Netflix's concurrency-limits.
+// While latency stays close to the lowest seen, the backend is assumed to have spare capacity and the limit grows; as latency rises, requests are assumed to be queueing and the limit shrinks.
+type AdaptiveConcurrency struct {
+	InitialLimit int // defaults to MinLimit
+	MinLimit     int // defaults to 1
+}
+
+// concurrencyLimiter caps how many getter calls may run at once, at maxConcurrentBatches unless AdaptiveConcurrency is set
+type concurrencyLimiter struct {
+	adaptive bool
+	min      float64
+	max      float64
+	limit    float64
+	minRTT   time.Duration
+
+	// samples are averaged over a window of about one sample per call allowed, so each adjustment reflects calls made under the previous limit
+	windowSamples  int
+	windowRTT      time.Duration
+	windowInFlight int
+}
+
+func newConcurrencyLimiter(adaptive *AdaptiveConcurrency, maxConcurrentBatches int) *concurrencyLimiter {
+	if adaptive == nil {
+		return &concurrencyLimiter{limit: float64(maxConcurrentBatches)}
+	}
+	minLimit := adaptive.MinLimit
+	if minLimit <= 0 {
+		minLimit = 1
+	}
+	if minLimit > maxConcurrentBatches {
+		minLimit = maxConcurrentBatches
+	}
+	initialLimit := adaptive.InitialLimit
+	if initialLimit < minLimit {
+		initialLimit = minLimit
+	}
+	if initialLimit > maxConcurrentBatches {
+		initialLimit = maxConcurrentBatches
+	}
+	return &concurrencyLimiter{
+		adaptive: true,
+		min:      float64(minLimit),
+		max:      float64(maxConcurrentBatches),
+		limit:    float64(initialLimit),
+	}
+}
+
+func (limiter *concurrencyLimiter) current() int {
+	return int(limiter.limit)
+}
+
+// observe adjusts the limit after a getter call that took rtt to return while inFlight calls (including itself) were running; failed calls are taken as a sign of overload
+func (limiter *concurrencyLimiter) observe(rtt time.Duration, inFlight int, failed bool) {
+	if !limiter.adaptive {
+		return
+	}
+	step := math.Max(1, math.Log10(limiter.limit))
+	if failed {
+		limiter.setLimit(limiter.limit - step)
+		return
+	}
+	if rtt <= 0 {
+		return
+	}
+	if limiter.minRTT == 0 || rtt < limiter.minRTT {
+		limiter.minRTT = rtt
+	}
+	limiter.windowSamples++
+	limiter.windowRTT += rtt
+	if inFlight > limiter.windowInFlight {
+		limiter.windowInFlight = inFlight
+	}
+	if float64(limiter.windowSamples) < limiter.limit {
+		return
+	}
+	rtt = limiter.windowRTT / time.Duration(limiter.windowSamples)
+	inFlight = limiter.windowInFlight
+	limiter.windowSamples = 0
+	limiter.windowRTT = 0
+	limiter.windowInFlight = 0
+
+	if float64(inFlight)*2 < limiter.limit {
+		return // too few calls are running to tell whether more would be too many
+	}
+	// estimate how many of the running calls are queued behind the others, rather than being served
+	queued := math.Ceil(limiter.limit * (1 - float64(limiter.minRTT)/float64(rtt)))
+	alpha := 3 * step
+	beta := 6 * step
+	switch {
+	case queued <= step:
+		limiter.setLimit(limiter.limit + beta)
+	case queued < alpha:
+		limiter.setLimit(limiter.limit + step)
+	case queued > beta:
+		limiter.setLimit(limiter.limit - step)
+	}
+}
+
+func (limiter *concurrencyLimiter) setLimit(limit float64) {
+	limiter.limit = math.Min(limiter.max, math.Max(limiter.min, limit))
+}
@@ -0,0 +1,135 @@
+package dataloader
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// simulatedBackend serves up to capacity calls at once at baseRTT, and beyond that slows down in proportion to how many calls it is sharing itself between
+type simulatedBackend struct {
+	capacity int
+	baseRTT  time.Duration
+}
+
+func (backend simulatedBackend) rtt(concurrent int) time.Duration {
+	if concurrent <= backend.capacity {
+		return backend.baseRTT
+	}
+	return backend.baseRTT * time.Duration(concurrent) / time.Duration(backend.capacity)
+}
+
+// simulateLimiter replays a busy service against the backend on a fake clock: whenever the limiter allows it another call is started, and it observes each call as it completes.
+// It returns the limit after each completion.
+func simulateLimiter(limiter *concurrencyLimiter, backend simulatedBackend, completions int) []int {
+	now := time.Time{}
+	type call struct {
+		started  time.Time
+		finishes time.Time
+	}
+	running := []call{}
+	limits := []int{}
+	for len(limits) < completions {
+		for len(running) < limiter.current() {
+			running = append(running, call{started: now, finishes: now.Add(backend.rtt(len(running) + 1))})
+		}
+		sort.Slice(running, func(i, j int) bool {
+			return running[i].finishes.Before(running[j].finishes)
+		})
+		next := running[0]
+		now = next.finishes
+		limiter.observe(now.Sub(next.started), len(running), false)
+		running = running[1:]
+		limits = append(limits, limiter.current())
+	}
+	return limits
+}
+
+func TestConcurrencyLimiterConverges(t *testing.T) {
+	backend := simulatedBackend{capacity: 10, baseRTT: 10 * time.Millisecond}
+	limiter := newConcurrencyLimiter(&AdaptiveConcurrency{}, 100)
+	limits := simulateLimiter(limiter, backend, 2000)
+	for _, limit := range limits[1000:] {
+		// it should settle a little above the backend's capacity, queueing a few calls but nowhere near the hard cap
+		if limit < backend.capacity || limit > 2*backend.capacity {
+			t.Fatal("concurrencyLimiter did not converge near the backend's capacity, got", limit)
+		}
+	}
+}
+
+func TestConcurrencyLimiterBounds(t *testing.T) {
+	backend := simulatedBackend{capacity: 1000, baseRTT: 10 * time.Millisecond}
+	limiter := newConcurrencyLimiter(&AdaptiveConcurrency{InitialLimit: 2}, 20)
+	for _, limit := range simulateLimiter(limiter, backend, 500) {
+		if limit > 20 {
+			t.Fatal("concurrencyLimiter exceeded maxConcurrentBatches, got", limit)
+		}
+	}
+	if limiter.current() != 20 {
+		t.Fatal("concurrencyLimiter did not grow to maxConcurrentBatches while the backend kept up, got", limiter.current())
+	}
+
+	for i := 0; i < 100; i++ {
+		limiter.observe(time.Second, limiter.current(), true)
+	}
+	if limiter.current() != 1 {
+		t.Fatal("concurrencyLimiter did not stop shrinking at MinLimit, got", limiter.current())
+	}
+
+	fixed := newConcurrencyLimiter(nil, 20)
+	fixed.observe(time.Hour, 20, true)
+	if fixed.current() != 20 {
+		t.Fatal("concurrencyLimiter changed its limit without AdaptiveConcurrency, got", fixed.current())
+	}
+}
+
+func TestQueryBatcherAdaptiveConcurrency(t *testing.T) {
+	concurrent := 0
+	maxConcurrent := 0
+	lock := &sync.Mutex{}
+	getter := func(keys []int) (map[int]int, map[int]error) {
+		lock.Lock()
+		concurrent++
+		if concurrent > maxConcurrent {
+			maxConcurrent = concurrent
+		}
+		lock.Unlock()
+		time.Sleep(time.Millisecond)
+		lock.Lock()
+		concurrent--
+		lock.Unlock()
+		result := map[int]int{}
+		for _, key := range keys {
+			result[key] = key
+		}
+		return result, nil
+	}
+
+	batcher := NewQueryBatcherWithOptions(getter, 4, 1, Options[int]{
+		AdaptiveConcurrency: &AdaptiveConcurrency{},
+	})
+	defer batcher.Close()
+	if batcher.Stats().ConcurrencyLimit != 1 {
+		t.Fatal("QueryBatcher did not start at the initial concurrency limit, got", batcher.Stats().ConcurrencyLimit)
+	}
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func(key int) {
+			defer wg.Done()
+			if _, err := batcher.Load(key); err != nil {
+				t.Error("QueryBatcher did not load key", key, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if maxConcurrent > 4 {
+		t.Fatal("QueryBatcher exceeded maxConcurrentBatches, got", maxConcurrent)
+	}
+	if limit := batcher.Stats().ConcurrencyLimit; limit < 1 || limit > 4 {
+		t.Fatal("QueryBatcher did not keep its concurrency limit within bounds, got", limit)
+	}
+}
@@ -0,0 +1,58 @@
+package dataloader
+
+import (
+	"context"
+	"time"
+
+	"github.com/preston-wagner/unicycle/defaults"
+	"github.com/preston-wagner/unicycle/promises"
+)
+
+type priorityContextKey struct{}
+
+// WithPriority returns a context that makes LoadContext and LoadPromiseContext batch keys at the given priority
+func WithPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityContextKey{}, priority)
+}
+
+func priorityFromContext(ctx context.Context) Priority {
+	if priority, ok := ctx.Value(priorityContextKey{}).(Priority); ok {
+		return priority
+	}
+	return PriorityNormal
+}
+
+// awaitContext waits for a promise, but gives up with the context's error if it is done first
+func awaitContext[VALUE_TYPE any](ctx context.Context, promise *promises.Promise[VALUE_TYPE]) (VALUE_TYPE, error) {
+	if ctx.Done() == nil {
+		return promise.Await()
+	}
+	result := make(chan promises.Promissory[VALUE_TYPE], 1)
+	go func() {
+		value, err := promise.Await()
+		result <- promises.Promissory[VALUE_TYPE]{Value: value, Err: err}
+	}()
+	select {
+	case prm := <-result:
+		return prm.Value, prm.Err
+	case <-ctx.Done():
+		return defaults.ZeroValue[VALUE_TYPE](), ctx.Err()
+	}
+}
+
+// detachedContext keeps the values of the context it wraps, but is never done, so that a promise shared by later callers isn't abandoned because the first caller gave up
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
@@ -1,45 +1,151 @@
 package dataloader
 
 import (
+	"context"
 	"sync"
 
 	"github.com/preston-wagner/unicycle/promises"
 )
 
 type DataLoader[KEY_TYPE comparable, VALUE_TYPE any] struct {
-	queryBatcher *QueryBatcher[KEY_TYPE, VALUE_TYPE]
-	promiseCache map[KEY_TYPE]*promises.Promise[VALUE_TYPE]
-	lock         *sync.RWMutex
+	*KeyedDataLoader[KEY_TYPE, KEY_TYPE, VALUE_TYPE]
 }
 
 func NewDataLoader[KEY_TYPE comparable, VALUE_TYPE any](getter Getter[KEY_TYPE, VALUE_TYPE], maxConcurrentBatches, maxBatchSize int) *DataLoader[KEY_TYPE, VALUE_TYPE] {
+	return NewDataLoaderWithOptions(getter, maxConcurrentBatches, maxBatchSize, Options[KEY_TYPE]{})
+}
+
+func NewDataLoaderWithOptions[KEY_TYPE comparable, VALUE_TYPE any](getter Getter[KEY_TYPE, VALUE_TYPE], maxConcurrentBatches, maxBatchSize int, options Options[KEY_TYPE]) *DataLoader[KEY_TYPE, VALUE_TYPE] {
 	return &DataLoader[KEY_TYPE, VALUE_TYPE]{
-		queryBatcher: NewQueryBatcher(getter, maxConcurrentBatches, maxBatchSize),
-		promiseCache: map[KEY_TYPE]*promises.Promise[VALUE_TYPE]{},
+		KeyedDataLoader: NewKeyedDataLoaderWithOptions(KeyedGetter[KEY_TYPE, KEY_TYPE, VALUE_TYPE](getter), identity[KEY_TYPE], maxConcurrentBatches, maxBatchSize, options),
+	}
+}
+
+// NewPartitionedDataLoader creates a DataLoader whose batches each contain keys from a single partition, as determined by options.PartitionFunc
+func NewPartitionedDataLoader[KEY_TYPE comparable, VALUE_TYPE any](getter PartitionedGetter[KEY_TYPE, VALUE_TYPE], maxConcurrentBatches, maxBatchSize int, options Options[KEY_TYPE]) *DataLoader[KEY_TYPE, VALUE_TYPE] {
+	return &DataLoader[KEY_TYPE, VALUE_TYPE]{
+		KeyedDataLoader: newKeyedDataLoader(NewPartitionedQueryBatcher(getter, maxConcurrentBatches, maxBatchSize, options).KeyedQueryBatcher),
+	}
+}
+
+// KeyedDataLoader is a DataLoader for keys that are not comparable, caching results by the cache key produced by its KeyFunc
+type KeyedDataLoader[KEY_TYPE any, CACHE_KEY comparable, VALUE_TYPE any] struct {
+	queryBatcher *KeyedQueryBatcher[KEY_TYPE, CACHE_KEY, VALUE_TYPE]
+	promiseCache map[CACHE_KEY]*promises.Promise[VALUE_TYPE]
+	lock         *sync.RWMutex
+}
+
+func NewKeyedDataLoader[KEY_TYPE any, CACHE_KEY comparable, VALUE_TYPE any](getter KeyedGetter[KEY_TYPE, CACHE_KEY, VALUE_TYPE], keyFunc KeyFunc[KEY_TYPE, CACHE_KEY], maxConcurrentBatches, maxBatchSize int) *KeyedDataLoader[KEY_TYPE, CACHE_KEY, VALUE_TYPE] {
+	return NewKeyedDataLoaderWithOptions(getter, keyFunc, maxConcurrentBatches, maxBatchSize, Options[KEY_TYPE]{})
+}
+
+func NewKeyedDataLoaderWithOptions[KEY_TYPE any, CACHE_KEY comparable, VALUE_TYPE any](getter KeyedGetter[KEY_TYPE, CACHE_KEY, VALUE_TYPE], keyFunc KeyFunc[KEY_TYPE, CACHE_KEY], maxConcurrentBatches, maxBatchSize int, options Options[KEY_TYPE]) *KeyedDataLoader[KEY_TYPE, CACHE_KEY, VALUE_TYPE] {
+	return newKeyedDataLoader(NewKeyedQueryBatcherWithOptions(getter, keyFunc, maxConcurrentBatches, maxBatchSize, options))
+}
+
+func newKeyedDataLoader[KEY_TYPE any, CACHE_KEY comparable, VALUE_TYPE any](queryBatcher *KeyedQueryBatcher[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) *KeyedDataLoader[KEY_TYPE, CACHE_KEY, VALUE_TYPE] {
+	return &KeyedDataLoader[KEY_TYPE, CACHE_KEY, VALUE_TYPE]{
+		queryBatcher: queryBatcher,
+		promiseCache: map[CACHE_KEY]*promises.Promise[VALUE_TYPE]{},
 		lock:         &sync.RWMutex{},
 	}
 }
 
-func (dataLoader *DataLoader[KEY_TYPE, VALUE_TYPE]) Load(key KEY_TYPE) (VALUE_TYPE, error) {
+func (dataLoader *KeyedDataLoader[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) Load(key KEY_TYPE) (VALUE_TYPE, error) {
 	return dataLoader.LoadPromise(key).Await()
 }
 
-func (dataLoader *DataLoader[KEY_TYPE, VALUE_TYPE]) LoadPromise(key KEY_TYPE) *promises.Promise[VALUE_TYPE] {
+func (dataLoader *KeyedDataLoader[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) LoadPromise(key KEY_TYPE) *promises.Promise[VALUE_TYPE] {
+	return dataLoader.LoadPromiseContext(context.Background(), key)
+}
+
+// LoadWithPriority is like Load, but if the key isn't already cached, it is batched ahead of pending keys with lower priority
+func (dataLoader *KeyedDataLoader[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) LoadWithPriority(key KEY_TYPE, priority Priority) (VALUE_TYPE, error) {
+	return dataLoader.LoadPromiseWithPriority(key, priority).Await()
+}
+
+func (dataLoader *KeyedDataLoader[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) LoadPromiseWithPriority(key KEY_TYPE, priority Priority) *promises.Promise[VALUE_TYPE] {
+	return dataLoader.LoadPromiseContext(WithPriority(context.Background(), priority), key)
+}
+
+// LoadContext is like Load, but returns the context's error if it is done before the key is loaded; the key stays cached for other callers either way
+func (dataLoader *KeyedDataLoader[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) LoadContext(ctx context.Context, key KEY_TYPE) (VALUE_TYPE, error) {
+	return awaitContext(ctx, dataLoader.LoadPromiseContext(ctx, key))
+}
+
+func (dataLoader *KeyedDataLoader[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) LoadPromiseContext(ctx context.Context, key KEY_TYPE) *promises.Promise[VALUE_TYPE] {
+	key, cacheKey, err := dataLoader.queryBatcher.prepareKey(key)
+	if err != nil {
+		return rejectedPromise[VALUE_TYPE](err)
+	}
 	dataLoader.lock.RLock()
-	promise, ok := dataLoader.promiseCache[key]
+	promise, ok := dataLoader.promiseCache[cacheKey]
 	dataLoader.lock.RUnlock()
 	if !ok {
 		dataLoader.lock.Lock()
 		defer dataLoader.lock.Unlock()
-		promise, ok = dataLoader.promiseCache[key] // it's possible it was set immediately after RUnlock on another goroutine
+		promise, ok = dataLoader.promiseCache[cacheKey] // it's possible it was set immediately after RUnlock on another goroutine
 		if !ok {
-			promise = dataLoader.queryBatcher.LoadPromise(key)
-			dataLoader.promiseCache[key] = promise
+			// the promise is shared by every later caller, so it mustn't be abandoned just because this caller gives up
+			promise = dataLoader.queryBatcher.loadPromise(detachedContext{ctx}, key, cacheKey)
+			dataLoader.promiseCache[cacheKey] = promise
 		}
 	}
 	return promise
 }
 
-func (dataLoader *DataLoader[KEY_TYPE, VALUE_TYPE]) Close() {
+// Clear removes a key from the cache, so that the next call to load it is passed to the getter again
+func (dataLoader *KeyedDataLoader[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) Clear(key KEY_TYPE) {
+	_, cacheKey, err := dataLoader.queryBatcher.prepareKey(key)
+	if err != nil {
+		return // invalid keys are never cached
+	}
+	dataLoader.lock.Lock()
+	defer dataLoader.lock.Unlock()
+	delete(dataLoader.promiseCache, cacheKey)
+}
+
+func (dataLoader *KeyedDataLoader[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) ClearAll() {
+	dataLoader.lock.Lock()
+	defer dataLoader.lock.Unlock()
+	dataLoader.promiseCache = map[CACHE_KEY]*promises.Promise[VALUE_TYPE]{}
+}
+
+// Prime caches a value for a key (such as one loaded by another query) without calling the getter; keys that are already cached keep their current value
+func (dataLoader *KeyedDataLoader[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) Prime(key KEY_TYPE, value VALUE_TYPE) {
+	_, cacheKey, err := dataLoader.queryBatcher.prepareKey(key)
+	if err != nil {
+		return
+	}
+	dataLoader.lock.Lock()
+	defer dataLoader.lock.Unlock()
+	if _, ok := dataLoader.promiseCache[cacheKey]; !ok {
+		promise := promises.NewPromise[VALUE_TYPE]()
+		promise.Resolve(value, nil)
+		dataLoader.promiseCache[cacheKey] = promise
+	}
+}
+
+// Stats returns a snapshot of the underlying QueryBatcher's pending keys and getter calls
+func (dataLoader *KeyedDataLoader[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) Stats() Stats {
+	return dataLoader.queryBatcher.Stats()
+}
+
+// PruneTenantStats removes the counters of idle tenants; see KeyedQueryBatcher.PruneTenantStats
+func (dataLoader *KeyedDataLoader[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) PruneTenantStats() {
+	dataLoader.queryBatcher.PruneTenantStats()
+}
+
+// Dispatch sends every pending key to the getter; see KeyedQueryBatcher.Dispatch
+func (dataLoader *KeyedDataLoader[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) Dispatch() {
+	dataLoader.queryBatcher.Dispatch()
+}
+
+// Flush dispatches every pending key, then blocks until every key passed to the getter so far has been resolved, or the DataLoader is closed
+func (dataLoader *KeyedDataLoader[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) Flush() {
+	dataLoader.queryBatcher.Flush()
+}
+
+func (dataLoader *KeyedDataLoader[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) Close() {
 	dataLoader.queryBatcher.Close()
 }
@@ -1,6 +1,9 @@
 package dataloader
 
 import (
+	"context"
+	"errors"
+	"sync"
 	"testing"
 	"time"
 )
@@ -51,11 +54,11 @@ func TestDataLoaderSuccessMany(t *testing.T) {
 
 	maxCalls := 30
 	for i := 1; i < maxCalls; i++ {
-		go batcher.Load(i)
+		batcher.LoadPromise(i)
 	}
 	batcher.Load(maxCalls)
 
-	time.Sleep(time.Second * 5)
+	batcher.Flush()
 
 	// due to the intricacies of goroutines and channels, as well as the speed of the actual hardware, the theoretical best-case performance of 4 calls may not always be reached
 	if calls > (maxCalls / 5) { // 6
@@ -70,14 +73,17 @@ func TestDataLoaderSuccessMany(t *testing.T) {
 func TestDataLoaderSuccessMultithread(t *testing.T) {
 	calls := 0
 	keysCount := 0
+	lock := &sync.Mutex{} // the getter runs on up to three goroutines at once
 	countCallsGetter := func(input []int) (map[int]int, map[int]error) {
+		lock.Lock()
 		calls += 1
+		keysCount += len(input)
+		lock.Unlock()
 
 		time.Sleep(time.Second)
 
 		result := map[int]int{}
 		for _, value := range input {
-			keysCount++
 			result[value] = -value
 		}
 		return result, nil
@@ -88,11 +94,11 @@ func TestDataLoaderSuccessMultithread(t *testing.T) {
 
 	maxCalls := 50
 	for i := 1; i < maxCalls; i++ {
-		go batcher.Load(i)
+		batcher.LoadPromise(i)
 	}
 	batcher.Load(maxCalls)
 
-	time.Sleep(time.Second * 5)
+	batcher.Flush()
 
 	if calls > (maxCalls / 2) {
 		t.Fatal("DataLoader did not batch the queries, made", calls, "calls")
@@ -102,3 +108,129 @@ func TestDataLoaderSuccessMultithread(t *testing.T) {
 		t.Fatal("DataLoader did not call the getter with all keys, used", keysCount, "keys")
 	}
 }
+
+func TestKeyedDataLoaderCache(t *testing.T) {
+	calls := 0
+	getter := func(keys []compositeKey) (map[string]int, map[string]error) {
+		calls++
+		result := map[string]int{}
+		for _, key := range keys {
+			result[compositeKeyFunc(key)] = len(key.Tags)
+		}
+		return result, nil
+	}
+
+	loader := NewKeyedDataLoader(getter, compositeKeyFunc, 1, 10)
+	defer loader.Close()
+
+	for i := 0; i < 3; i++ {
+		result, err := loader.Load(compositeKey{Tenant: "acme", Tags: []string{"a", "b", "c"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != 3 {
+			t.Fatal("KeyedDataLoader did not return the expected result for the query")
+		}
+	}
+
+	if calls != 1 {
+		t.Fatal("KeyedDataLoader did not cache by the cache key, made", calls, "calls")
+	}
+}
+
+func TestDataLoaderNormalize(t *testing.T) {
+	calls := 0
+	getter := func(keys []string) (map[string]string, map[string]error) {
+		calls++
+		return alwaysSucceedGetter(keys)
+	}
+
+	loader := NewDataLoaderWithOptions(getter, 1, 10, Options[string]{Normalize: normalizeEmail})
+	defer loader.Close()
+
+	for _, key := range []string{"Bob@Example.com", "bob@example.com", "BOB@EXAMPLE.COM "} {
+		result, err := loader.Load(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reverseString(result) != "bob@example.com" {
+			t.Fatal("DataLoader did not return the result for the normalized key, got", result)
+		}
+	}
+
+	if calls != 1 {
+		t.Fatal("DataLoader did not cache by the normalized key, made", calls, "calls")
+	}
+
+	_, err := loader.Load("bob")
+	if !errors.Is(err, ErrInvalidKey) {
+		t.Fatal("DataLoader did not reject a key that failed normalization with ErrInvalidKey, got", err)
+	}
+	if calls != 1 {
+		t.Fatal("DataLoader called the getter for a key that failed normalization")
+	}
+}
+
+func TestDataLoaderClearAndPrime(t *testing.T) {
+	calls := 0
+	getter := func(keys []string) (map[string]string, map[string]error) {
+		calls++
+		return alwaysSucceedGetter(keys)
+	}
+
+	var loader CachingLoader[string, string] = NewDataLoaderWithOptions(getter, 1, 10, Options[string]{Normalize: normalizeEmail})
+	defer loader.Close()
+
+	loader.Prime("Alice@Example.com", "primed")
+	if result, err := loader.Load("alice@example.com"); err != nil || result != "primed" {
+		t.Fatal("DataLoader did not return the primed value for the normalized key, got", result, err)
+	}
+	loader.Prime("alice@example.com", "ignored")
+	if result, _ := loader.Load("alice@example.com"); result != "primed" {
+		t.Fatal("DataLoader overwrote a cached value when primed, got", result)
+	}
+	if calls != 0 {
+		t.Fatal("DataLoader called the getter for a primed key")
+	}
+
+	loader.Clear("ALICE@example.com")
+	if result, _ := loader.Load("alice@example.com"); result != reverseString("alice@example.com") {
+		t.Fatal("DataLoader did not reload a cleared key, got", result)
+	}
+	loader.Load("bob@example.com")
+	loader.ClearAll()
+	loader.Load("alice@example.com")
+	loader.Load("bob@example.com")
+	if calls != 4 {
+		t.Fatal("DataLoader did not reload keys after ClearAll, made", calls, "calls")
+	}
+}
+
+func TestDataLoaderCancelledFirstCaller(t *testing.T) {
+	calls := 0
+	getter := func(keys []string) (map[string]string, map[string]error) {
+		calls++
+		return alwaysSucceedGetter(keys)
+	}
+	loader := NewDataLoaderWithOptions(getter, 1, 10, Options[string]{Manual: true})
+	defer loader.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := loader.LoadPromiseContext(ctx, "lorem")
+	cancel()
+	if _, err := loader.LoadContext(ctx, "lorem"); !errors.Is(err, context.Canceled) {
+		t.Fatal("DataLoader did not return the cancelled caller's context error, got", err)
+	}
+	loader.Flush()
+
+	// the cached promise must not have been abandoned along with the first caller
+	if result, err := loader.Load("lorem"); err != nil || result != "merol" {
+		t.Fatal("DataLoader cached the cancelled caller's error for later callers, got", result, err)
+	}
+	if result, err := first.Await(); err != nil || result != "merol" {
+		t.Fatal("DataLoader did not resolve the first caller's promise, got", result, err)
+	}
+	if calls != 1 {
+		t.Fatal("DataLoader did not call the getter exactly once, made", calls, "calls")
+	}
+}
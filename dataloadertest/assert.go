@@ -0,0 +1,23 @@
+package dataloadertest
+
+import "testing"
+
+// AssertNoDuplicateKeys fails the test if any key was passed to the recorded getter more than once
+func AssertNoDuplicateKeys[KEY_TYPE comparable, VALUE_TYPE any](t testing.TB, recorder *Recorder[KEY_TYPE, VALUE_TYPE]) {
+	t.Helper()
+	seen := map[KEY_TYPE]struct{}{}
+	for _, key := range recorder.Keys() {
+		if _, ok := seen[key]; ok {
+			t.Error("key", key, "was passed to the getter more than once")
+		}
+		seen[key] = struct{}{}
+	}
+}
+
+// AssertMaxBatches fails the test if the recorded getter was called more than max times
+func AssertMaxBatches[KEY_TYPE comparable, VALUE_TYPE any](t testing.TB, recorder *Recorder[KEY_TYPE, VALUE_TYPE], max int) {
+	t.Helper()
+	if batches := len(recorder.Batches()); batches > max {
+		t.Error("the getter was called", batches, "times, expected at most", max)
+	}
+}
@@ -0,0 +1,112 @@
+package dataloadertest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/preston-wagner/go-dataloader"
+)
+
+// FakeClock is a dataloader.Clock whose time only moves when Advance is called, firing any timers that come due
+type FakeClock struct {
+	lock    *sync.Mutex
+	changed *sync.Cond // broadcast whenever a timer is added
+	now     time.Time
+	timers  []*fakeTimer
+}
+
+var _ dataloader.Clock = &FakeClock{}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	lock := &sync.Mutex{}
+	return &FakeClock{
+		lock:    lock,
+		changed: sync.NewCond(lock),
+		now:     now,
+	}
+}
+
+func (clock *FakeClock) Now() time.Time {
+	clock.lock.Lock()
+	defer clock.lock.Unlock()
+	return clock.now
+}
+
+func (clock *FakeClock) After(d time.Duration) <-chan time.Time {
+	return clock.NewTimer(d).C()
+}
+
+func (clock *FakeClock) NewTimer(d time.Duration) dataloader.Timer {
+	clock.lock.Lock()
+	defer clock.lock.Unlock()
+	timer := &fakeTimer{
+		clock:    clock,
+		deadline: clock.now.Add(d),
+		c:        make(chan time.Time, 1),
+	}
+	if d <= 0 {
+		timer.c <- clock.now
+	} else {
+		clock.timers = append(clock.timers, timer)
+		clock.changed.Broadcast()
+	}
+	return timer
+}
+
+// Advance moves the clock forward, firing the timers that come due in the order of their deadlines
+func (clock *FakeClock) Advance(d time.Duration) {
+	clock.lock.Lock()
+	defer clock.lock.Unlock()
+	clock.now = clock.now.Add(d)
+	sort.SliceStable(clock.timers, func(i, j int) bool {
+		return clock.timers[i].deadline.Before(clock.timers[j].deadline)
+	})
+	remaining := []*fakeTimer{}
+	for _, timer := range clock.timers {
+		if timer.deadline.After(clock.now) {
+			remaining = append(remaining, timer)
+		} else {
+			timer.c <- timer.deadline
+		}
+	}
+	clock.timers = remaining
+}
+
+// Timers returns how many timers are waiting to fire
+func (clock *FakeClock) Timers() int {
+	clock.lock.Lock()
+	defer clock.lock.Unlock()
+	return len(clock.timers)
+}
+
+// BlockUntilTimers waits until at least count timers are waiting to fire, so that a test can be sure a goroutine has started waiting before it calls Advance
+func (clock *FakeClock) BlockUntilTimers(count int) {
+	clock.lock.Lock()
+	defer clock.lock.Unlock()
+	for len(clock.timers) < count {
+		clock.changed.Wait()
+	}
+}
+
+type fakeTimer struct {
+	clock    *FakeClock
+	deadline time.Time
+	c        chan time.Time
+}
+
+func (timer *fakeTimer) C() <-chan time.Time {
+	return timer.c
+}
+
+func (timer *fakeTimer) Stop() bool {
+	timer.clock.lock.Lock()
+	defer timer.clock.lock.Unlock()
+	for i, other := range timer.clock.timers {
+		if other == timer {
+			timer.clock.timers = append(timer.clock.timers[:i], timer.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package dataloadertest
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/preston-wagner/go-dataloader"
+	"github.com/preston-wagner/unicycle/promises"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	later := clock.NewTimer(2 * time.Second)
+	sooner := clock.After(time.Second)
+	stopped := clock.NewTimer(time.Second)
+	if !stopped.Stop() || stopped.Stop() {
+		t.Fatal("FakeClock did not stop a pending timer exactly once")
+	}
+	clock.BlockUntilTimers(2)
+
+	clock.Advance(1500 * time.Millisecond)
+	if fired := <-sooner; !fired.Equal(start.Add(time.Second)) {
+		t.Fatal("FakeClock did not fire a timer at its deadline, got", fired)
+	}
+	select {
+	case <-later.C():
+		t.Fatal("FakeClock fired a timer before its deadline")
+	case <-stopped.C():
+		t.Fatal("FakeClock fired a stopped timer")
+	default:
+	}
+	clock.Advance(time.Second)
+	<-later.C()
+	if !clock.Now().Equal(start.Add(2500*time.Millisecond)) || clock.Timers() != 0 {
+		t.Fatal("FakeClock did not advance, got", clock.Now(), clock.Timers())
+	}
+}
+
+func TestFakeClockRateLimit(t *testing.T) {
+	clock := NewFakeClock(time.Time{})
+	recorder := NewRecorder(func(keys []int) (map[int]int, map[int]error) {
+		result := map[int]int{}
+		for _, key := range keys {
+			result[key] = key
+		}
+		return result, nil
+	})
+	batcher := dataloader.NewQueryBatcherWithOptions(recorder.Get, 10, 100, dataloader.Options[int]{
+		RateLimit: &dataloader.RateLimit{BatchesPerSecond: 1, BatchBurst: 1},
+		Clock:     clock,
+	})
+	defer batcher.Close()
+
+	batcher.Load(0) // uses up the only token
+	loads := []*promises.Promise[int]{}
+	for i := 1; i <= 5; i++ {
+		loads = append(loads, batcher.LoadPromise(i))
+	}
+	for batcher.Stats().Pending < 5 {
+		runtime.Gosched()
+	}
+	clock.BlockUntilTimers(1)
+	if len(recorder.Batches()) != 1 {
+		t.Fatal("QueryBatcher did not wait for the rate limit, made", len(recorder.Batches()), "calls")
+	}
+
+	clock.Advance(time.Second)
+	promises.AwaitAll(loads...)
+	AssertMaxBatches(t, recorder, 2)
+}
+
+func TestFakeClockHedgedGetter(t *testing.T) {
+	clock := NewFakeClock(time.Time{})
+	slow := false
+	lock := &sync.Mutex{}
+	cancelled := make(chan struct{}, 1)
+	primary := func(ctx context.Context, keys []int) (map[int]int, map[int]error) {
+		lock.Lock()
+		isSlow := slow
+		lock.Unlock()
+		if isSlow {
+			// only returns once the hedge has won
+			<-ctx.Done()
+			cancelled <- struct{}{}
+			return nil, dataloader.ErrForAll(keys, ctx.Err())
+		}
+		return negate(keys)
+	}
+	alternate := NewRecorder(negate)
+	getter := dataloader.HedgedGetter(primary, func(ctx context.Context, keys []int) (map[int]int, map[int]error) {
+		return alternate.Get(keys)
+	}, dataloader.HedgePolicy{
+		MinDelay: 10 * time.Millisecond,
+		Window:   20,
+		Clock:    clock,
+	})
+
+	for i := 0; i < 20; i++ {
+		getter([]int{i})
+	}
+	if len(alternate.Batches()) != 0 {
+		t.Fatal("HedgedGetter hedged calls that returned quickly, made", len(alternate.Batches()))
+	}
+
+	lock.Lock()
+	slow = true
+	lock.Unlock()
+	result := make(chan map[int]int)
+	go func() {
+		values, errs := getter([]int{1, 2})
+		if len(errs) != 0 {
+			t.Error("HedgedGetter returned errors from the losing call", errs)
+		}
+		result <- values
+	}()
+	clock.BlockUntilTimers(1)
+	clock.Advance(10 * time.Millisecond)
+	if values := <-result; values[1] != -1 || values[2] != -2 {
+		t.Fatal("HedgedGetter did not return the alternate's response", values)
+	}
+	if len(alternate.Batches()) != 1 {
+		t.Fatal("HedgedGetter did not hedge a slow call, made", len(alternate.Batches()))
+	}
+	<-cancelled // the losing call is only cancelled once the getter has returned
+}
@@ -0,0 +1,82 @@
+package dataloadertest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/preston-wagner/go-dataloader"
+	"github.com/preston-wagner/unicycle/promises"
+)
+
+func negate(keys []int) (map[int]int, map[int]error) {
+	result := map[int]int{}
+	for _, key := range keys {
+		result[key] = -key
+	}
+	return result, nil
+}
+
+// failureRecorder records failures instead of failing the test, to check that assertions fail when they should
+type failureRecorder struct {
+	testing.TB
+	failures int
+}
+
+func (recorder *failureRecorder) Helper() {}
+
+func (recorder *failureRecorder) Error(args ...any) {
+	recorder.failures++
+}
+
+func TestRecorder(t *testing.T) {
+	recorder := NewRecorder(negate)
+	loader := dataloader.NewDataLoader(recorder.Get, 1, 10)
+	defer loader.Close()
+
+	loads := []*promises.Promise[int]{}
+	for i := 0; i < 25; i++ {
+		loads = append(loads, loader.LoadPromise(i%20))
+	}
+	loader.Flush()
+	if len(recorder.Keys()) != 20 {
+		t.Fatal("Recorder did not record every key, got", recorder.Keys())
+	}
+	AssertNoDuplicateKeys(t, recorder)
+	AssertMaxBatches(t, recorder, 20)
+	for i, result := range promises.AwaitAll(loads...) {
+		if result.Err != nil || result.Value != -(i%20) {
+			t.Fatal("Recorder did not pass the getter's response through, got", result.Value, result.Err)
+		}
+	}
+
+	recorder.Get([]int{3})
+	failures := &failureRecorder{TB: t}
+	AssertNoDuplicateKeys(failures, recorder)
+	AssertMaxBatches(failures, recorder, 1)
+	if failures.failures != 2 {
+		t.Fatal("assertions did not fail for a duplicate key and too many batches, got", failures.failures, "failures")
+	}
+}
+
+func TestFakeLoader(t *testing.T) {
+	errBanned := errors.New("banned")
+	var loader dataloader.CachingLoader[string, string] = NewFakeLoader(map[string]string{"1": "alice"}, map[string]error{"2": errBanned})
+
+	if name, err := loader.Load("1"); err != nil || name != "alice" {
+		t.Fatal("FakeLoader did not return the value it was given, got", name, err)
+	}
+	if _, err := loader.Load("2"); !errors.Is(err, errBanned) {
+		t.Fatal("FakeLoader did not return the error it was given, got", err)
+	}
+	if _, err := loader.Load("3"); !errors.Is(err, dataloader.ErrMissingResponse) {
+		t.Fatal("FakeLoader did not return ErrMissingResponse for an unknown key, got", err)
+	}
+	loader.Prime("3", "carol")
+	if name, _ := loader.Load("3"); name != "carol" {
+		t.Fatal("FakeLoader did not return a primed value, got", name)
+	}
+	loader.Clear("1")
+	if _, err := loader.Load("1"); !errors.Is(err, dataloader.ErrMissingResponse) {
+		t.Fatal("FakeLoader did not clear a key, got", err)
+	}
+}
@@ -0,0 +1,100 @@
+package dataloadertest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/preston-wagner/go-dataloader"
+	"github.com/preston-wagner/unicycle/defaults"
+	"github.com/preston-wagner/unicycle/promises"
+)
+
+// FakeLoader is an in-memory dataloader.CachingLoader for substituting in tests; keys without a value or error fail with dataloader.ErrMissingResponse
+type FakeLoader[KEY_TYPE comparable, VALUE_TYPE any] struct {
+	lock   *sync.Mutex
+	values map[KEY_TYPE]VALUE_TYPE
+	errs   map[KEY_TYPE]error
+	loaded []KEY_TYPE
+}
+
+var _ dataloader.CachingLoader[int, int] = &FakeLoader[int, int]{}
+
+func NewFakeLoader[KEY_TYPE comparable, VALUE_TYPE any](values map[KEY_TYPE]VALUE_TYPE, errs map[KEY_TYPE]error) *FakeLoader[KEY_TYPE, VALUE_TYPE] {
+	fake := &FakeLoader[KEY_TYPE, VALUE_TYPE]{
+		lock:   &sync.Mutex{},
+		values: map[KEY_TYPE]VALUE_TYPE{},
+		errs:   map[KEY_TYPE]error{},
+	}
+	for key, value := range values {
+		fake.values[key] = value
+	}
+	for key, err := range errs {
+		fake.errs[key] = err
+	}
+	return fake
+}
+
+func (fake *FakeLoader[KEY_TYPE, VALUE_TYPE]) Load(key KEY_TYPE) (VALUE_TYPE, error) {
+	return fake.LoadPromise(key).Await()
+}
+
+func (fake *FakeLoader[KEY_TYPE, VALUE_TYPE]) LoadPromise(key KEY_TYPE) *promises.Promise[VALUE_TYPE] {
+	return fake.LoadPromiseContext(context.Background(), key)
+}
+
+func (fake *FakeLoader[KEY_TYPE, VALUE_TYPE]) LoadContext(ctx context.Context, key KEY_TYPE) (VALUE_TYPE, error) {
+	if err := ctx.Err(); err != nil {
+		return defaults.ZeroValue[VALUE_TYPE](), err
+	}
+	return fake.LoadPromiseContext(ctx, key).Await()
+}
+
+func (fake *FakeLoader[KEY_TYPE, VALUE_TYPE]) LoadPromiseContext(_ context.Context, key KEY_TYPE) *promises.Promise[VALUE_TYPE] {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	fake.loaded = append(fake.loaded, key)
+	promise := promises.NewPromise[VALUE_TYPE]()
+	if value, ok := fake.values[key]; ok {
+		promise.Resolve(value, nil)
+	} else if err, ok := fake.errs[key]; ok {
+		promise.Resolve(defaults.ZeroValue[VALUE_TYPE](), err)
+	} else {
+		promise.Resolve(defaults.ZeroValue[VALUE_TYPE](), dataloader.ErrMissingResponse)
+	}
+	return promise
+}
+
+// Loaded returns every key loaded so far, in order, including repeats
+func (fake *FakeLoader[KEY_TYPE, VALUE_TYPE]) Loaded() []KEY_TYPE {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	return append([]KEY_TYPE{}, fake.loaded...)
+}
+
+// Clear removes a key's value or error, so that it fails with dataloader.ErrMissingResponse
+func (fake *FakeLoader[KEY_TYPE, VALUE_TYPE]) Clear(key KEY_TYPE) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	delete(fake.values, key)
+	delete(fake.errs, key)
+}
+
+func (fake *FakeLoader[KEY_TYPE, VALUE_TYPE]) ClearAll() {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	fake.values = map[KEY_TYPE]VALUE_TYPE{}
+	fake.errs = map[KEY_TYPE]error{}
+}
+
+// Prime sets a key's value, unless it already has a value or error, like DataLoader.Prime
+func (fake *FakeLoader[KEY_TYPE, VALUE_TYPE]) Prime(key KEY_TYPE, value VALUE_TYPE) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	_, hasValue := fake.values[key]
+	_, hasErr := fake.errs[key]
+	if !hasValue && !hasErr {
+		fake.values[key] = value
+	}
+}
+
+func (fake *FakeLoader[KEY_TYPE, VALUE_TYPE]) Close() {}
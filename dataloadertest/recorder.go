@@ -0,0 +1,47 @@
+package dataloadertest
+
+import (
+	"sync"
+
+	"github.com/preston-wagner/go-dataloader"
+)
+
+// Recorder wraps a getter, recording the keys of every batch it is called with
+type Recorder[KEY_TYPE comparable, VALUE_TYPE any] struct {
+	getter  dataloader.Getter[KEY_TYPE, VALUE_TYPE]
+	lock    *sync.Mutex
+	batches [][]KEY_TYPE
+}
+
+func NewRecorder[KEY_TYPE comparable, VALUE_TYPE any](getter dataloader.Getter[KEY_TYPE, VALUE_TYPE]) *Recorder[KEY_TYPE, VALUE_TYPE] {
+	return &Recorder[KEY_TYPE, VALUE_TYPE]{
+		getter: getter,
+		lock:   &sync.Mutex{},
+	}
+}
+
+// Get records the batch and passes it to the wrapped getter; pass recorder.Get wherever a Getter is expected
+func (recorder *Recorder[KEY_TYPE, VALUE_TYPE]) Get(keys []KEY_TYPE) (map[KEY_TYPE]VALUE_TYPE, map[KEY_TYPE]error) {
+	recorder.lock.Lock()
+	recorder.batches = append(recorder.batches, append([]KEY_TYPE{}, keys...))
+	recorder.lock.Unlock()
+	return recorder.getter(keys)
+}
+
+// Batches returns the keys of every batch recorded so far, in the order the getter was called
+func (recorder *Recorder[KEY_TYPE, VALUE_TYPE]) Batches() [][]KEY_TYPE {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	batches := make([][]KEY_TYPE, len(recorder.batches))
+	copy(batches, recorder.batches)
+	return batches
+}
+
+// Keys returns every key recorded so far, including any requested more than once
+func (recorder *Recorder[KEY_TYPE, VALUE_TYPE]) Keys() []KEY_TYPE {
+	keys := []KEY_TYPE{}
+	for _, btch := range recorder.Batches() {
+		keys = append(keys, btch...)
+	}
+	return keys
+}
@@ -7,10 +7,38 @@ import (
 
 var ErrMissingResponse = errors.New("no data or explicit error was returned for the given key")
 
+var ErrInvalidKey = errors.New("invalid key")
+
+// ErrClosed is returned for keys loaded after Close, or still waiting to be dispatched when it was called
+var ErrClosed = errors.New("loader is closed")
+
 type GetterPanicError struct {
 	recovered any
 }
 
+// NewGetterPanicError wraps a value recovered from a panic, for getters that run code on goroutines of their own, where the QueryBatcher can't recover it
+func NewGetterPanicError(recovered any) GetterPanicError {
+	return GetterPanicError{recovered: recovered}
+}
+
 func (gpe GetterPanicError) Error() string {
 	return fmt.Sprintf("panic in getter: %v", gpe.recovered)
 }
+
+// InvalidKeyError is returned for keys rejected by the Normalize or Validate options; it matches ErrInvalidKey with errors.Is, and unwraps to the reason the key was rejected
+type InvalidKeyError struct {
+	key    any
+	reason error
+}
+
+func (ike InvalidKeyError) Error() string {
+	return fmt.Sprintf("%v %v: %v", ErrInvalidKey, ike.key, ike.reason)
+}
+
+func (ike InvalidKeyError) Is(target error) bool {
+	return target == ErrInvalidKey
+}
+
+func (ike InvalidKeyError) Unwrap() error {
+	return ike.reason
+}
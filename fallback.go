@@ -0,0 +1,54 @@
+package dataloader
+
+import "errors"
+
+// FallbackOptions configures FallbackGetter; its zero value retries every failed key and writes nothing back
+type FallbackOptions[KEY_TYPE comparable, VALUE_TYPE any] struct {
+	// Retryable reports whether a key the primary getter failed with the given error should be requested from the secondary; keys the primary didn't respond to at all are always retried
+	Retryable func(error) bool
+
+	// WriteBack is called with the values the secondary getter found, such as to store them in a cache service used as the primary, before they are returned
+	WriteBack func(values map[KEY_TYPE]VALUE_TYPE)
+}
+
+// FallbackGetter calls primary, then calls secondary with only the keys primary didn't find or failed with a retryable error, and merges their responses
+func FallbackGetter[KEY_TYPE comparable, VALUE_TYPE any](primary, secondary Getter[KEY_TYPE, VALUE_TYPE], options FallbackOptions[KEY_TYPE, VALUE_TYPE]) Getter[KEY_TYPE, VALUE_TYPE] {
+	return func(keys []KEY_TYPE) (map[KEY_TYPE]VALUE_TYPE, map[KEY_TYPE]error) {
+		values, errs := primary(keys)
+		if values == nil {
+			values = map[KEY_TYPE]VALUE_TYPE{}
+		}
+		if errs == nil {
+			errs = map[KEY_TYPE]error{}
+		}
+
+		retry := []KEY_TYPE{}
+		for _, key := range keys {
+			if _, ok := values[key]; ok {
+				continue
+			}
+			if err, ok := errs[key]; !ok || errors.Is(err, ErrMissingResponse) || options.Retryable == nil || options.Retryable(err) {
+				retry = append(retry, key)
+			}
+		}
+		if len(retry) == 0 {
+			return values, errs
+		}
+
+		secondaryValues, secondaryErrs := secondary(retry)
+		found := map[KEY_TYPE]VALUE_TYPE{}
+		for _, key := range retry {
+			delete(errs, key) // the secondary's response replaces the primary's error, and a key neither responded to is left missing
+			if value, ok := secondaryValues[key]; ok {
+				values[key] = value
+				found[key] = value
+			} else if err, ok := secondaryErrs[key]; ok {
+				errs[key] = err
+			}
+		}
+		if options.WriteBack != nil && len(found) > 0 {
+			options.WriteBack(found)
+		}
+		return values, errs
+	}
+}
@@ -0,0 +1,75 @@
+package dataloader
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+var errCacheUnavailable = errors.New("cache unavailable")
+var errNotFound = errors.New("not found")
+
+func TestFallbackGetter(t *testing.T) {
+	cache := map[int]int{1: 10}
+	cacheGetter := func(keys []int) (map[int]int, map[int]error) {
+		values := map[int]int{}
+		errs := map[int]error{}
+		for _, key := range keys {
+			if value, ok := cache[key]; ok {
+				values[key] = value
+			} else if key == 2 {
+				errs[key] = errCacheUnavailable
+			} else if key == 3 {
+				errs[key] = errNotFound
+			}
+		}
+		return values, errs
+	}
+	var requested []int
+	databaseGetter := func(keys []int) (map[int]int, map[int]error) {
+		requested = append(requested, keys...)
+		values := map[int]int{}
+		errs := map[int]error{}
+		for _, key := range keys {
+			if key == 5 {
+				errs[key] = errNotFound
+			} else {
+				values[key] = key * 10
+			}
+		}
+		return values, errs
+	}
+
+	getter := FallbackGetter(cacheGetter, databaseGetter, FallbackOptions[int, int]{
+		Retryable: func(err error) bool {
+			return err == errCacheUnavailable
+		},
+		WriteBack: func(values map[int]int) {
+			for key, value := range values {
+				cache[key] = value
+			}
+		},
+	})
+	values, errs := getter([]int{1, 2, 3, 4, 5})
+
+	sort.Ints(requested)
+	if !reflect.DeepEqual(requested, []int{2, 4, 5}) {
+		t.Fatal("FallbackGetter did not request only missing and retryable keys from the secondary, got", requested)
+	}
+	if !reflect.DeepEqual(values, map[int]int{1: 10, 2: 20, 4: 40}) {
+		t.Fatal("FallbackGetter did not merge the values of both getters, got", values)
+	}
+	if errs[3] != errNotFound || errs[5] != errNotFound || len(errs) != 2 {
+		t.Fatal("FallbackGetter did not merge the errors of both getters, got", errs)
+	}
+	if cache[2] != 20 || cache[4] != 40 {
+		t.Fatal("FallbackGetter did not write the secondary's values back, got", cache)
+	}
+
+	requested = nil
+	getter([]int{1, 2, 4})
+	if len(requested) != 0 {
+		t.Fatal("FallbackGetter called the secondary for keys the primary found, got", requested)
+	}
+}
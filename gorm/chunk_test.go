@@ -0,0 +1,118 @@
+package gormLoader
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/preston-wagner/go-dataloader"
+	"github.com/preston-wagner/go-dataloader/dataloadertest"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+var errChunkFailed = errors.New("chunk failed")
+
+// failQueriesFor makes every query that is passed the given key fail, to simulate a chunk hitting a database error
+func failQueriesFor(t *testing.T, db *gorm.DB, failKey string) {
+	err := db.Callback().Query().After("gorm:query").Register("test:fail_chunk", func(tx *gorm.DB) {
+		for _, variable := range tx.Statement.Vars {
+			if variable == failKey {
+				tx.AddError(errChunkFailed)
+				return
+			}
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGormGetterChunksSQLite(t *testing.T) {
+	for _, concurrentChunks := range []int{1, 3} {
+		db := openSQLite(t, &TestTable{})
+		rows := []TestTable{
+			{ID: "a", Col1: 1},
+			{ID: "b", Col1: 1},
+			{ID: "c", Col1: 2},
+			{ID: "d", Col1: 2},
+			{ID: "e", Col1: 3},
+		}
+		if err := db.Create(&rows).Error; err != nil {
+			t.Fatal(err)
+		}
+		failQueriesFor(t, db, "c")
+
+		getter := GormGetter(db, "id", getId, WithChunkSize(2), WithConcurrentChunks(concurrentChunks))
+		recorder := dataloadertest.NewRecorder(getter)
+		values, errs := recorder.Get([]string{"a", "b", "c", "d", "e", "f"})
+
+		assert.Equal(t, rows[0], values["a"])
+		assert.Equal(t, rows[1], values["b"])
+		assert.Equal(t, rows[4], values["e"])
+		assert.NotContains(t, values, "f")
+		// only the keys sharing a chunk with the failing key are rejected
+		assert.ErrorIs(t, errs["c"], errChunkFailed)
+		assert.ErrorIs(t, errs["d"], errChunkFailed)
+		assert.Len(t, errs, 2)
+		dataloadertest.AssertMaxBatches(t, recorder, 1)
+	}
+}
+
+func TestGormListGetterChunksSQLite(t *testing.T) {
+	db := openSQLite(t, &TestTable{})
+	rows := []TestTable{
+		{ID: "a", Col1: 1},
+		{ID: "b", Col1: 1},
+		{ID: "c", Col1: 2},
+		{ID: "d", Col1: 3},
+	}
+	if err := db.Create(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	loader := dataloader.NewDataLoaderWithOptions(GormListGetter(db, "col1", getCol1, WithChunkSize(1), WithConcurrentChunks(2)), 1, 10, dataloader.Options[int]{Manual: true})
+	defer loader.Close()
+	ones := loader.LoadPromise(1)
+	twos := loader.LoadPromise(2)
+	fours := loader.LoadPromise(4)
+	loader.Flush()
+
+	items, err := ones.Await()
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, rows[:2], items)
+	items, err = twos.Await()
+	assert.NoError(t, err)
+	assert.Equal(t, rows[2:3], items)
+	items, err = fours.Await()
+	assert.NoError(t, err)
+	assert.Empty(t, items)
+}
+
+func TestGormGetterChunkPanicSQLite(t *testing.T) {
+	for _, concurrentChunks := range []int{1, 3} {
+		db := openSQLite(t, &TestTable{})
+		rows := []TestTable{{ID: "a"}, {ID: "b"}, {ID: "c"}, {ID: "d"}}
+		if err := db.Create(&rows).Error; err != nil {
+			t.Fatal(err)
+		}
+		// a user hook that panics, for the chunk containing "c"
+		err := db.Callback().Query().After("gorm:query").Register("test:panic_chunk", func(tx *gorm.DB) {
+			for _, variable := range tx.Statement.Vars {
+				if variable == "c" {
+					panic("hook failed")
+				}
+			}
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		values, errs := GormGetter(db, "id", getId, WithChunkSize(2), WithConcurrentChunks(concurrentChunks))([]string{"a", "b", "c", "d"})
+		assert.Equal(t, rows[0], values["a"])
+		assert.Equal(t, rows[1], values["b"])
+		assert.Len(t, errs, 2)
+		for _, key := range []string{"c", "d"} {
+			assert.ErrorAs(t, errs[key], &dataloader.GetterPanicError{})
+		}
+	}
+}
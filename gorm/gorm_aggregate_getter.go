@@ -0,0 +1,61 @@
+package gormLoader
+
+import (
+	"github.com/preston-wagner/go-dataloader"
+	"github.com/preston-wagner/go-dataloader/internal/chunk"
+	"gorm.io/gorm"
+)
+
+type keyCount[KEY_TYPE any] struct {
+	Key   KEY_TYPE `gorm:"column:dataloader_key"`
+	Count int64    `gorm:"column:dataloader_count"`
+}
+
+// GormCountGetter counts the rows of MODEL_TYPE for each key with a single GROUP BY query; keys without any rows are counted as 0.
+// WithScopes and WithWhere filter the rows counted, and the other query options have no effect.
+func GormCountGetter[KEY_TYPE comparable, MODEL_TYPE any](db *gorm.DB, columnName string, options ...Option) dataloader.Getter[KEY_TYPE, int64] {
+	opts := newGetterOptions(options)
+	checkPostgresArray(db, opts)
+	return func(keys []KEY_TYPE) (map[KEY_TYPE]int64, map[KEY_TYPE]error) {
+		counts, succeeded, errs := chunk.Query(keys, opts.chunks, func(chunk []KEY_TYPE) ([]keyCount[KEY_TYPE], error) {
+			counts := []keyCount[KEY_TYPE]{}
+			result := whereKeys(opts.filter(db.Model(new(MODEL_TYPE))), columnName, chunk, opts).
+				Select(columnName + " AS dataloader_key, COUNT(*) AS dataloader_count").
+				Group(columnName).
+				Scan(&counts)
+			return counts, result.Error
+		})
+		values := make(map[KEY_TYPE]int64, len(succeeded))
+		for _, key := range succeeded {
+			values[key] = 0
+		}
+		for _, count := range counts {
+			values[count.Key] = count.Count
+		}
+		return values, errs
+	}
+}
+
+// GormExistsGetter reports whether there are any rows of MODEL_TYPE for each key.
+// WithScopes and WithWhere filter the rows considered, and the other query options have no effect.
+func GormExistsGetter[KEY_TYPE comparable, MODEL_TYPE any](db *gorm.DB, columnName string, options ...Option) dataloader.Getter[KEY_TYPE, bool] {
+	opts := newGetterOptions(options)
+	checkPostgresArray(db, opts)
+	return func(keys []KEY_TYPE) (map[KEY_TYPE]bool, map[KEY_TYPE]error) {
+		found, succeeded, errs := chunk.Query(keys, opts.chunks, func(chunk []KEY_TYPE) ([]KEY_TYPE, error) {
+			found := []KEY_TYPE{}
+			result := whereKeys(opts.filter(db.Model(new(MODEL_TYPE))), columnName, chunk, opts).
+				Distinct(columnName).
+				Pluck(columnName, &found)
+			return found, result.Error
+		})
+		values := make(map[KEY_TYPE]bool, len(succeeded))
+		for _, key := range succeeded {
+			values[key] = false
+		}
+		for _, key := range found {
+			values[key] = true
+		}
+		return values, errs
+	}
+}
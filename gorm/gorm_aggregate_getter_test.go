@@ -0,0 +1,43 @@
+package gormLoader
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestGormCountGetterSQLite(t *testing.T) {
+	db := openCommentsSQLite(t)
+	keys := []string{"a", "b", "c"}
+
+	// soft-deleted rows aren't counted
+	counts, errs := GormCountGetter[string, Comment](db, "post_id")(keys)
+	assert.Empty(t, errs)
+	assert.Equal(t, map[string]int64{"a": 5, "b": 2, "c": 0}, counts)
+
+	counts, errs = GormCountGetter[string, Comment](db, "post_id", WithWhere("body = ?", "ipsum"), WithChunkSize(1))(keys)
+	assert.Empty(t, errs)
+	assert.Equal(t, map[string]int64{"a": 0, "b": 1, "c": 0}, counts)
+}
+
+func TestGormExistsGetterSQLite(t *testing.T) {
+	db := openCommentsSQLite(t)
+	keys := []string{"a", "b", "c"}
+
+	exists, errs := GormExistsGetter[string, Comment](db, "post_id")(keys)
+	assert.Empty(t, errs)
+	assert.Equal(t, map[string]bool{"a": true, "b": true, "c": false}, exists)
+
+	exists, errs = GormExistsGetter[string, Comment](db, "post_id", WithScopes(func(db *gorm.DB) *gorm.DB {
+		return db.Where("id > ?", 6)
+	}))(keys)
+	assert.Empty(t, errs)
+	assert.Equal(t, map[string]bool{"a": false, "b": true, "c": false}, exists)
+
+	// row getters take the same conditions
+	comments, errs := GormListGetter(db, "post_id", getCommentPostId, WithWhere("body = ?", "dolor"))(keys)
+	assert.Empty(t, errs)
+	assert.Equal(t, []int{8}, commentIds(comments["b"]))
+	assert.Empty(t, comments["a"])
+}
@@ -0,0 +1,92 @@
+package gormLoader
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/preston-wagner/go-dataloader"
+	"github.com/preston-wagner/go-dataloader/internal/chunk"
+	"github.com/preston-wagner/unicycle/slices"
+	"gorm.io/gorm"
+)
+
+// GormCompositeGetter is like GormGetter, but for tables keyed by several columns, such as (org_id, id).
+// KEY_TYPE must be a struct with one exported field per column, in the same order as columnNames; WithPostgresArray isn't supported, since a row value can't be compared with = ANY.
+func GormCompositeGetter[KEY_TYPE comparable, VALUE_TYPE any](db *gorm.DB, columnNames []string, keyGetter func(VALUE_TYPE) KEY_TYPE, options ...Option) dataloader.Getter[KEY_TYPE, VALUE_TYPE] {
+	checkCompositeKey[KEY_TYPE](columnNames)
+	opts := newGetterOptions(options)
+	checkPostgresArray(db, opts)
+	if opts.postgresArray {
+		panic("WithPostgresArray can't be used with composite keys")
+	}
+	return func(keys []KEY_TYPE) (map[KEY_TYPE]VALUE_TYPE, map[KEY_TYPE]error) {
+		values, _, errs := chunk.Query(keys, opts.chunks, func(chunk []KEY_TYPE) ([]VALUE_TYPE, error) {
+			values := []VALUE_TYPE{}
+			result := whereCompositeKeys(opts.query(db), columnNames, chunk, rowValuesSupported(db)).Find(&values)
+			return values, result.Error
+		})
+		return slices.KeyBy(values, keyGetter), errs
+	}
+}
+
+// checkCompositeKey panics if KEY_TYPE can't be used as a composite key of the given columns
+func checkCompositeKey[KEY_TYPE any](columnNames []string) {
+	keyType := reflect.TypeOf((*KEY_TYPE)(nil)).Elem()
+	if keyType.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("composite key type %v must be a struct", keyType))
+	}
+	if keyType.NumField() != len(columnNames) {
+		panic(fmt.Sprintf("composite key type %v has %d fields, but %d columns were given", keyType, keyType.NumField(), len(columnNames)))
+	}
+	for i := 0; i < keyType.NumField(); i++ {
+		if !keyType.Field(i).IsExported() {
+			panic(fmt.Sprintf("composite key type %v has unexported field %v", keyType, keyType.Field(i).Name))
+		}
+	}
+}
+
+// compositeKeyValues returns the fields of a composite key, in order
+func compositeKeyValues[KEY_TYPE any](key KEY_TYPE) []any {
+	keyValue := reflect.ValueOf(key)
+	values := make([]any, keyValue.NumField())
+	for i := range values {
+		values[i] = keyValue.Field(i).Interface()
+	}
+	return values
+}
+
+// rowValuesSupported reports whether the database understands row-value comparisons like (a, b) IN ((?, ?), ...)
+func rowValuesSupported(db *gorm.DB) bool {
+	switch db.Dialector.Name() {
+	case "postgres", "mysql", "sqlite":
+		return true
+	default:
+		return false
+	}
+}
+
+// whereCompositeKeys filters to rows matching any of the keys, either with a row-value IN, or with OR-ed conditions for databases that don't support them
+func whereCompositeKeys[KEY_TYPE any](db *gorm.DB, columnNames []string, keys []KEY_TYPE, rowValues bool) *gorm.DB {
+	if rowValues {
+		tuples := make([][]any, len(keys))
+		for i, key := range keys {
+			tuples[i] = compositeKeyValues(key)
+		}
+		return db.Where("("+strings.Join(columnNames, ", ")+") IN ?", tuples)
+	}
+	conditions := make([]string, len(columnNames))
+	for i, columnName := range columnNames {
+		conditions[i] = columnName + " = ?"
+	}
+	condition := strings.Join(conditions, " AND ")
+	anyKey := db.Session(&gorm.Session{NewDB: true})
+	for i, key := range keys {
+		if i == 0 {
+			anyKey = anyKey.Where(condition, compositeKeyValues(key)...)
+		} else {
+			anyKey = anyKey.Or(condition, compositeKeyValues(key)...)
+		}
+	}
+	return db.Where(anyKey)
+}
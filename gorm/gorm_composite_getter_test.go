@@ -0,0 +1,95 @@
+package gormLoader
+
+import (
+	"testing"
+
+	"github.com/preston-wagner/go-dataloader"
+	"github.com/preston-wagner/go-dataloader/dataloadertest"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type Membership struct {
+	OrgID  string `gorm:"primaryKey"`
+	UserID int    `gorm:"primaryKey"`
+	Role   string
+}
+
+type membershipKey struct {
+	OrgID  string
+	UserID int
+}
+
+func getMembershipKey(membership Membership) membershipKey {
+	return membershipKey{OrgID: membership.OrgID, UserID: membership.UserID}
+}
+
+var memberships = []Membership{
+	{OrgID: "acme", UserID: 1, Role: "admin"},
+	{OrgID: "acme", UserID: 2, Role: "member"},
+	{OrgID: "globex", UserID: 1, Role: "member"},
+}
+
+func testGormCompositeGetter(t *testing.T, db *gorm.DB) {
+	if err := db.Create(&memberships).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := dataloadertest.NewRecorder(GormCompositeGetter(db, []string{"org_id", "user_id"}, getMembershipKey))
+	loader := dataloader.NewDataLoaderWithOptions(recorder.Get, 1, 10, dataloader.Options[membershipKey]{Manual: true})
+	defer loader.Close()
+
+	loads := map[membershipKey]*Membership{}
+	for _, membership := range memberships {
+		membership := membership
+		loads[getMembershipKey(membership)] = &membership
+	}
+	loads[membershipKey{OrgID: "globex", UserID: 2}] = nil // matches a column of two rows, but not both
+
+	promises := map[membershipKey]func() (Membership, error){}
+	for key := range loads {
+		promise := loader.LoadPromise(key)
+		promises[key] = promise.Await
+	}
+	loader.Flush()
+	for key, expected := range loads {
+		membership, err := promises[key]()
+		if expected == nil {
+			assert.ErrorIs(t, err, dataloader.ErrMissingResponse)
+		} else {
+			assert.NoError(t, err)
+			assert.Equal(t, *expected, membership)
+		}
+	}
+	dataloadertest.AssertMaxBatches(t, recorder, 1)
+}
+
+func TestGormCompositeGetterSQLite(t *testing.T) {
+	testGormCompositeGetter(t, openSQLite(t, &Membership{}))
+}
+
+func TestGormCompositeGetterPostgres(t *testing.T) {
+	testGormCompositeGetter(t, openPostgres(t, &Membership{}))
+}
+
+func TestGormCompositeGetterOrFallback(t *testing.T) {
+	db := openSQLite(t, &Membership{})
+	if err := db.Create(&memberships).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	found := []Membership{}
+	keys := []membershipKey{{OrgID: "acme", UserID: 2}, {OrgID: "globex", UserID: 1}, {OrgID: "globex", UserID: 2}}
+	err := whereCompositeKeys(db, []string{"org_id", "user_id"}, keys, false).Order("org_id").Find(&found).Error
+	assert.NoError(t, err)
+	assert.Equal(t, []Membership{memberships[1], memberships[2]}, found)
+}
+
+func TestGormCompositeGetterKeyType(t *testing.T) {
+	assert.Panics(t, func() {
+		GormCompositeGetter(nil, []string{"org_id"}, getMembershipKey)
+	})
+	assert.Panics(t, func() {
+		GormCompositeGetter(nil, []string{"id"}, func(membership Membership) string { return membership.OrgID })
+	})
+}
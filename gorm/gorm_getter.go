@@ -1,31 +1,62 @@
 package gormLoader
 
 import (
+	"fmt"
+
+	"github.com/lib/pq"
 	"github.com/preston-wagner/go-dataloader"
+	"github.com/preston-wagner/go-dataloader/internal/chunk"
 	"github.com/preston-wagner/unicycle/slices"
 	"gorm.io/gorm"
 )
 
 // GormGetter trims out boilerplate for the common task of fetching individual rows by a single key
-func GormGetter[KEY_TYPE comparable, VALUE_TYPE any](db *gorm.DB, columnName string, keyGetter func(VALUE_TYPE) KEY_TYPE) dataloader.Getter[KEY_TYPE, VALUE_TYPE] {
+func GormGetter[KEY_TYPE comparable, VALUE_TYPE any](db *gorm.DB, columnName string, keyGetter func(VALUE_TYPE) KEY_TYPE, options ...Option) dataloader.Getter[KEY_TYPE, VALUE_TYPE] {
+	opts := newGetterOptions(options)
+	checkPostgresArray(db, opts)
 	return func(keys []KEY_TYPE) (map[KEY_TYPE]VALUE_TYPE, map[KEY_TYPE]error) {
-		values := []VALUE_TYPE{}
-		result := db.Where(columnName+" IN ?", keys).Find(&values)
-		if result.Error != nil {
-			return nil, dataloader.ErrForAll(keys, result.Error)
-		}
-		return slices.KeyBy(values, keyGetter), nil
+		values, _, errs := chunk.Query(keys, opts.chunks, func(chunk []KEY_TYPE) ([]VALUE_TYPE, error) {
+			return findIn[VALUE_TYPE](db, columnName, chunk, opts)
+		})
+		return slices.KeyBy(values, keyGetter), errs
 	}
 }
 
 // like GormGetter, except instead of fetching individual rows for each key, selects a slice of matches
-func GormListGetter[KEY_TYPE comparable, VALUE_TYPE any](db *gorm.DB, columnName string, keyGetter func(VALUE_TYPE) KEY_TYPE) dataloader.Getter[KEY_TYPE, []VALUE_TYPE] {
+func GormListGetter[KEY_TYPE comparable, VALUE_TYPE any](db *gorm.DB, columnName string, keyGetter func(VALUE_TYPE) KEY_TYPE, options ...Option) dataloader.Getter[KEY_TYPE, []VALUE_TYPE] {
+	opts := newGetterOptions(options)
+	checkPostgresArray(db, opts)
 	return func(keys []KEY_TYPE) (map[KEY_TYPE][]VALUE_TYPE, map[KEY_TYPE]error) {
-		values := []VALUE_TYPE{}
-		result := db.Where(columnName+" IN ?", keys).Find(&values)
-		if result.Error != nil {
-			return nil, dataloader.ErrForAll(keys, result.Error)
-		}
-		return dataloader.FillEmpty(keys, slices.GroupBy(values, keyGetter)), nil
+		values, succeeded, errs := chunk.Query(keys, opts.chunks, func(chunk []KEY_TYPE) ([]VALUE_TYPE, error) {
+			return findIn[VALUE_TYPE](db, columnName, chunk, opts)
+		})
+		// keys whose chunk failed are left out, so they are rejected rather than resolved as empty
+		return dataloader.FillEmpty(succeeded, slices.GroupBy(values, keyGetter)), errs
+	}
+}
+
+func findIn[VALUE_TYPE any, KEY_TYPE any](db *gorm.DB, columnName string, keys []KEY_TYPE, opts getterOptions) ([]VALUE_TYPE, error) {
+	values := []VALUE_TYPE{}
+	result := whereKeys(opts.query(db), columnName, keys, opts).Find(&values)
+	return values, result.Error
+}
+
+func whereKeys[KEY_TYPE any](db *gorm.DB, columnName string, keys []KEY_TYPE, opts getterOptions) *gorm.DB {
+	condition, arg := keysCondition(columnName, keys, opts)
+	return db.Where(condition, arg)
+}
+
+// keysCondition returns a condition matching rows whose column is any of the keys, and the argument to pass with it
+func keysCondition[KEY_TYPE any](columnName string, keys []KEY_TYPE, opts getterOptions) (string, any) {
+	if opts.postgresArray {
+		return columnName + " = ANY(?)", pq.Array(keys)
+	}
+	return columnName + " IN ?", keys
+}
+
+// checkPostgresArray panics if WithPostgresArray was used with another database
+func checkPostgresArray(db *gorm.DB, opts getterOptions) {
+	if opts.postgresArray && db.Dialector.Name() != "postgres" {
+		panic(fmt.Sprintf("WithPostgresArray can't be used with %v", db.Dialector.Name()))
 	}
 }
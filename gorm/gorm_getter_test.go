@@ -1,14 +1,14 @@
 package gormLoader
 
 import (
-	"sync"
 	"testing"
 
+	"github.com/glebarez/sqlite"
 	"github.com/nuvi/go-dockerdb"
 	"github.com/preston-wagner/go-dataloader"
+	"github.com/preston-wagner/go-dataloader/dataloadertest"
 	"github.com/preston-wagner/unicycle/multithread"
 	"github.com/preston-wagner/unicycle/promises"
-	"github.com/preston-wagner/unicycle/sets"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -29,21 +29,40 @@ func getCol1(tst TestTable) int {
 	return tst.Col1
 }
 
-func deduplicationTester[KEY_TYPE comparable, VALUE_TYPE any](t *testing.T, getter dataloader.Getter[KEY_TYPE, VALUE_TYPE]) dataloader.Getter[KEY_TYPE, VALUE_TYPE] {
-	calledKeys := sets.Set[KEY_TYPE]{}
-	lock := &sync.RWMutex{}
-	return func(keys []KEY_TYPE) (map[KEY_TYPE]VALUE_TYPE, map[KEY_TYPE]error) {
-		lock.Lock()
-		for _, key := range keys {
-			if calledKeys.Has(key) {
-				t.Error("deduplicationTester found that key", key, "was passed to a getter more than once!")
-			} else {
-				calledKeys.Add(key)
-			}
-		}
-		lock.Unlock()
-		return getter(keys)
+// openSQLite opens an in-memory SQLite database with the given tables, for tests that don't need Postgres
+func openSQLite(t *testing.T, models ...any) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1) // every connection to :memory: would otherwise get its own empty database
+	t.Cleanup(func() {
+		sqlDB.Close()
+	})
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// openPostgres starts a Postgres container with the given tables
+func openPostgres(t *testing.T, models ...any) *gorm.DB {
+	container, connectURL := dockerdb.SetupSuite()
+	t.Cleanup(func() {
+		dockerdb.StopContainer(container)
+	})
+	db, err := gorm.Open(postgres.Open(connectURL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
 	}
+	return db
 }
 
 func TestGormGetter(t *testing.T) {
@@ -85,7 +104,8 @@ func TestGormGetter(t *testing.T) {
 	db.Create(&item3)
 
 	// test single item lookups, including multiple at the same time
-	gormLoader := dataloader.NewDataLoader(deduplicationTester(t, GormGetter(db, "id", getId)), 2, 10)
+	recorder := dataloadertest.NewRecorder(GormGetter(db, "id", getId))
+	gormLoader := dataloader.NewDataLoader(recorder.Get, 2, 10)
 
 	_, err = gormLoader.Load("n/a")
 	assert.ErrorIs(t, err, dataloader.ErrMissingResponse)
@@ -104,9 +124,11 @@ func TestGormGetter(t *testing.T) {
 			assert.Equal(t, item, itemCopy)
 		}
 	}, 5)
+	dataloadertest.AssertNoDuplicateKeys(t, recorder)
 
 	// test list lookups
-	gormListLoader := dataloader.NewDataLoader(deduplicationTester(t, GormListGetter(db, "col1", getCol1)), 2, 10)
+	listRecorder := dataloadertest.NewRecorder(GormListGetter(db, "col1", getCol1))
+	gormListLoader := dataloader.NewDataLoader(listRecorder.Get, 2, 10)
 
 	promises.AwaitAll(
 		promises.WrapInPromise(func() (bool, error) {
@@ -130,4 +152,5 @@ func TestGormGetter(t *testing.T) {
 			return true, nil
 		}),
 	)
+	dataloadertest.AssertNoDuplicateKeys(t, listRecorder)
 }
@@ -0,0 +1,52 @@
+package gormLoader
+
+import (
+	"strings"
+
+	"github.com/preston-wagner/go-dataloader"
+	"github.com/preston-wagner/go-dataloader/internal/chunk"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// joinRow is a row of the target table, along with the source key it was joined to
+type joinRow[KEY_TYPE any, VALUE_TYPE any] struct {
+	Key   KEY_TYPE   `gorm:"column:dataloader_key"`
+	Value VALUE_TYPE `gorm:"embedded"`
+}
+
+// GormJoinTableGetter loads the rows of a many-to-many association, such as the tags of each post, by joining through the association's join table in a single query.
+// joinTable is named as in a many2many tag, so the naming strategy's table prefix is applied to it; sourceColumn is its column holding the keys, and targetColumn its column referencing VALUE_TYPE's primary key.
+// Soft-deleted rows of VALUE_TYPE are left out as usual. WithScopes, WithWhere, WithSelect and WithOrder apply to the target table, so columns they name that are also in the join table must be qualified with the table name; WithPreload has no effect.
+func GormJoinTableGetter[KEY_TYPE comparable, VALUE_TYPE any](db *gorm.DB, joinTable, sourceColumn, targetColumn string, options ...Option) dataloader.Getter[KEY_TYPE, []VALUE_TYPE] {
+	opts := newGetterOptions(options)
+	checkPostgresArray(db, opts)
+	joinTable = db.NamingStrategy.JoinTableName(joinTable)
+	return func(keys []KEY_TYPE) (map[KEY_TYPE][]VALUE_TYPE, map[KEY_TYPE]error) {
+		rows, succeeded, errs := chunk.Query(keys, opts.chunks, func(chunk []KEY_TYPE) ([]joinRow[KEY_TYPE, VALUE_TYPE], error) {
+			rows := []joinRow[KEY_TYPE, VALUE_TYPE]{}
+			tx := opts.filter(db.Model(new(VALUE_TYPE))).
+				Joins("INNER JOIN ? ON ? = ?",
+					clause.Table{Name: joinTable},
+					clause.Column{Table: joinTable, Name: targetColumn},
+					clause.Column{Table: clause.CurrentTable, Name: clause.PrimaryKey},
+				)
+			sourceKey := clause.Column{Table: joinTable, Name: sourceColumn}
+			if len(opts.selects) > 0 {
+				tx = tx.Select(strings.Join(opts.selects, ", ")+", ? AS dataloader_key", sourceKey)
+			} else {
+				tx = tx.Select("?.*, ? AS dataloader_key", clause.Table{Name: clause.CurrentTable}, sourceKey)
+			}
+			for _, order := range opts.orders {
+				tx = tx.Order(order)
+			}
+			result := whereKeys(tx, joinTable+"."+sourceColumn, chunk, opts).Find(&rows)
+			return rows, result.Error
+		})
+		values := map[KEY_TYPE][]VALUE_TYPE{}
+		for _, row := range rows {
+			values[row.Key] = append(values[row.Key], row.Value)
+		}
+		return dataloader.FillEmpty(succeeded, values), errs
+	}
+}
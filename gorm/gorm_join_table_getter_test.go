@@ -0,0 +1,71 @@
+package gormLoader
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+type Article struct {
+	ID    int `gorm:"primaryKey"`
+	Title string
+	Tags  []Tag `gorm:"many2many:article_tags"`
+}
+
+type Tag struct {
+	ID        int `gorm:"primaryKey"`
+	Name      string
+	DeletedAt gorm.DeletedAt
+}
+
+func tagNames(tags []Tag) []string {
+	names := []string{}
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	return names
+}
+
+func testGormJoinTableGetter(t *testing.T, db *gorm.DB) {
+	if err := db.AutoMigrate(&Article{}, &Tag{}); err != nil {
+		t.Fatal(err)
+	}
+	tags := []Tag{{ID: 1, Name: "go"}, {ID: 2, Name: "sql"}, {ID: 3, Name: "gone"}}
+	articles := []Article{
+		{ID: 1, Title: "lorem", Tags: tags},
+		{ID: 2, Title: "ipsum", Tags: tags[1:2]},
+		{ID: 3, Title: "dolor"},
+	}
+	if err := db.Create(&articles).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Delete(&tags[2]).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tagsByArticle, errs := GormJoinTableGetter[int, Tag](db, "article_tags", "article_id", "tag_id", WithOrder("name DESC"))([]int{1, 2, 3, 4})
+	assert.Empty(t, errs)
+	assert.Equal(t, []string{"sql", "go"}, tagNames(tagsByArticle[1]))
+	assert.Equal(t, []string{"sql"}, tagNames(tagsByArticle[2]))
+	assert.Empty(t, tagsByArticle[3])
+	assert.Empty(t, tagsByArticle[4])
+	assert.Len(t, tagsByArticle, 4)
+
+	tagsByArticle, errs = GormJoinTableGetter[int, Tag](db, "article_tags", "article_id", "tag_id", WithWhere("name LIKE ?", "s%"), WithChunkSize(1))([]int{1, 2})
+	assert.Empty(t, errs)
+	assert.Equal(t, []string{"sql"}, tagNames(tagsByArticle[1]))
+	assert.Equal(t, []string{"sql"}, tagNames(tagsByArticle[2]))
+}
+
+func TestGormJoinTableGetterSQLite(t *testing.T) {
+	testGormJoinTableGetter(t, openSQLite(t))
+}
+
+func TestGormJoinTableGetterPrefixedSQLite(t *testing.T) {
+	db := openSQLite(t)
+	db.NamingStrategy = schema.NamingStrategy{TablePrefix: "app_"}
+	testGormJoinTableGetter(t, db)
+	assert.True(t, db.Migrator().HasTable("app_article_tags"))
+}
@@ -0,0 +1,130 @@
+package gormLoader
+
+import (
+	"strings"
+
+	"github.com/preston-wagner/go-dataloader"
+	"github.com/preston-wagner/go-dataloader/internal/chunk"
+	"github.com/preston-wagner/unicycle/slices"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GormTopNGetter is like GormListGetter, but loads at most limit rows for each key: the first ones when ordered by orderBy, such as "created_at DESC".
+// Each key's slice is in that order; WithOrder has no effect. Columns passed to WithSelect must include a soft delete column, if the model has one.
+func GormTopNGetter[KEY_TYPE comparable, VALUE_TYPE any](db *gorm.DB, columnName string, keyGetter func(VALUE_TYPE) KEY_TYPE, limit int, orderBy string, options ...Option) dataloader.Getter[KEY_TYPE, []VALUE_TYPE] {
+	opts := newGetterOptions(options)
+	checkPostgresArray(db, opts)
+	return func(keys []KEY_TYPE) (map[KEY_TYPE][]VALUE_TYPE, map[KEY_TYPE]error) {
+		values, succeeded, errs := chunk.Query(keys, opts.chunks, func(chunk []KEY_TYPE) ([]VALUE_TYPE, error) {
+			return findTopN[VALUE_TYPE](db, columnName, limit, orderBy, opts, func(tx *gorm.DB) *gorm.DB {
+				return whereKeys(tx, columnName, chunk, opts)
+			})
+		})
+		return dataloader.FillEmpty(succeeded, slices.GroupBy(values, keyGetter)), errs
+	}
+}
+
+// Page identifies a page of the rows for a key: the first page if After is the zero value, otherwise the rows after the one whose cursor column is After.
+// Since the cursor is part of the key, each page is cached separately.
+type Page[KEY_TYPE comparable, CURSOR_TYPE comparable] struct {
+	Key   KEY_TYPE
+	After CURSOR_TYPE
+}
+
+// GormPageGetter is like GormTopNGetter, but loads pages of up to limit rows ordered by cursorColumn, which must be unique, such as an id or a unique timestamp.
+// The rows are in ascending order, or descending if descending is set, in which case each page holds the rows before its cursor rather than after it.
+func GormPageGetter[KEY_TYPE comparable, CURSOR_TYPE comparable, VALUE_TYPE any](db *gorm.DB, columnName string, keyGetter func(VALUE_TYPE) KEY_TYPE, cursorColumn string, descending bool, limit int, options ...Option) dataloader.Getter[Page[KEY_TYPE, CURSOR_TYPE], []VALUE_TYPE] {
+	opts := newGetterOptions(options)
+	checkPostgresArray(db, opts)
+	orderBy, comparison := cursorColumn+" ASC", " > ?"
+	if descending {
+		orderBy, comparison = cursorColumn+" DESC", " < ?"
+	}
+	return func(pages []Page[KEY_TYPE, CURSOR_TYPE]) (map[Page[KEY_TYPE, CURSOR_TYPE]][]VALUE_TYPE, map[Page[KEY_TYPE, CURSOR_TYPE]]error) {
+		results := map[Page[KEY_TYPE, CURSOR_TYPE]][]VALUE_TYPE{}
+		errs := map[Page[KEY_TYPE, CURSOR_TYPE]]error{}
+		for _, round := range pageRounds(pages) {
+			values, succeeded, roundErrs := chunk.Query(round, opts.chunks, func(chunk []Page[KEY_TYPE, CURSOR_TYPE]) ([]VALUE_TYPE, error) {
+				return findTopN[VALUE_TYPE](db, columnName, limit, orderBy, opts, func(tx *gorm.DB) *gorm.DB {
+					return wherePages(tx, columnName, cursorColumn+comparison, chunk, opts)
+				})
+			})
+			// each key is only in one page per round, so its rows belong to that page
+			pageByKey := map[KEY_TYPE]Page[KEY_TYPE, CURSOR_TYPE]{}
+			for _, page := range succeeded {
+				pageByKey[page.Key] = page
+				results[page] = []VALUE_TYPE{}
+			}
+			for _, value := range values {
+				page := pageByKey[keyGetter(value)]
+				results[page] = append(results[page], value)
+			}
+			for page, err := range roundErrs {
+				errs[page] = err
+			}
+		}
+		return results, errs
+	}
+}
+
+// pageRounds splits pages into rounds that each contain at most one page per key, since pages of the same key can't be told apart when queried together
+func pageRounds[KEY_TYPE comparable, CURSOR_TYPE comparable](pages []Page[KEY_TYPE, CURSOR_TYPE]) [][]Page[KEY_TYPE, CURSOR_TYPE] {
+	rounds := [][]Page[KEY_TYPE, CURSOR_TYPE]{}
+	roundsPerKey := map[KEY_TYPE]int{}
+	for _, page := range pages {
+		round := roundsPerKey[page.Key]
+		roundsPerKey[page.Key]++
+		if round == len(rounds) {
+			rounds = append(rounds, nil)
+		}
+		rounds[round] = append(rounds[round], page)
+	}
+	return rounds
+}
+
+// wherePages filters to the rows of any of the pages: those of keys on their first page, and those past each other page's cursor
+func wherePages[KEY_TYPE comparable, CURSOR_TYPE comparable](db *gorm.DB, columnName, cursorCondition string, pages []Page[KEY_TYPE, CURSOR_TYPE], opts getterOptions) *gorm.DB {
+	var zero CURSOR_TYPE
+	firstPages := []KEY_TYPE{}
+	anyPage := db.Session(&gorm.Session{NewDB: true})
+	conditions := 0
+	or := func(query string, args ...any) {
+		if conditions == 0 {
+			anyPage = anyPage.Where(query, args...)
+		} else {
+			anyPage = anyPage.Or(query, args...)
+		}
+		conditions++
+	}
+	for _, page := range pages {
+		if page.After == zero {
+			firstPages = append(firstPages, page.Key)
+		} else {
+			or(columnName+" = ? AND "+cursorCondition, page.Key, page.After)
+		}
+	}
+	if len(firstPages) > 0 {
+		condition, arg := keysCondition(columnName, firstPages, opts)
+		or(condition, arg)
+	}
+	return db.Where(anyPage)
+}
+
+// findTopN loads the rows matched by where, numbered within each value of the column by orderBy, keeping only the first limit of each
+func findTopN[VALUE_TYPE any](db *gorm.DB, columnName string, limit int, orderBy string, opts getterOptions, where func(*gorm.DB) *gorm.DB) ([]VALUE_TYPE, error) {
+	rowNumber := "ROW_NUMBER() OVER (PARTITION BY " + columnName + " ORDER BY " + orderBy + ") AS dataloader_row_number"
+	ranked := where(opts.filter(db.Model(new(VALUE_TYPE))))
+	if len(opts.selects) > 0 {
+		ranked = ranked.Select(strings.Join(opts.selects, ", ") + ", " + rowNumber)
+	} else {
+		ranked = ranked.Select("?.*, "+rowNumber, clause.Table{Name: clause.CurrentTable})
+	}
+
+	values := []VALUE_TYPE{}
+	result := opts.preload(db.Table("(?) AS dataloader_ranked", ranked)).
+		Where("dataloader_row_number <= ?", limit).
+		Order("dataloader_row_number").
+		Find(&values)
+	return values, result.Error
+}
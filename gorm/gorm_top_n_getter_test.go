@@ -0,0 +1,114 @@
+package gormLoader
+
+import (
+	"testing"
+
+	"github.com/preston-wagner/go-dataloader"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type Comment struct {
+	ID        int `gorm:"primaryKey"`
+	PostID    string
+	Body      string
+	DeletedAt gorm.DeletedAt
+}
+
+func getCommentPostId(comment Comment) string {
+	return comment.PostID
+}
+
+func commentIds(comments []Comment) []int {
+	ids := []int{}
+	for _, comment := range comments {
+		ids = append(ids, comment.ID)
+	}
+	return ids
+}
+
+func openCommentsSQLite(t *testing.T) *gorm.DB {
+	db := openSQLite(t, &Comment{})
+	comments := []Comment{}
+	for id := 1; id <= 6; id++ {
+		comments = append(comments, Comment{ID: id, PostID: "a", Body: "lorem"})
+	}
+	comments = append(comments, Comment{ID: 7, PostID: "b", Body: "ipsum"}, Comment{ID: 8, PostID: "b", Body: "dolor"})
+	if err := db.Create(&comments).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Delete(&Comment{ID: 3}).Error; err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestGormTopNGetterSQLite(t *testing.T) {
+	db := openCommentsSQLite(t)
+	keys := []string{"a", "b", "c"}
+
+	latest, errs := GormTopNGetter(db, "post_id", getCommentPostId, 2, "id DESC")(keys)
+	assert.Empty(t, errs)
+	assert.Equal(t, []int{6, 5}, commentIds(latest["a"]))
+	assert.Equal(t, []int{8, 7}, commentIds(latest["b"]))
+	assert.Empty(t, latest["c"])
+	assert.Contains(t, latest, "c")
+
+	// soft-deleted rows aren't counted towards the limit
+	earliest, errs := GormTopNGetter(db, "post_id", getCommentPostId, 4, "id ASC", WithChunkSize(1))(keys)
+	assert.Empty(t, errs)
+	assert.Equal(t, []int{1, 2, 4, 5}, commentIds(earliest["a"]))
+	assert.Equal(t, []int{7, 8}, commentIds(earliest["b"]))
+
+	selected, errs := GormTopNGetter(db, "post_id", getCommentPostId, 1, "id ASC", WithSelect("id", "post_id", "deleted_at"))(keys)
+	assert.Empty(t, errs)
+	assert.Equal(t, []Comment{{ID: 1, PostID: "a"}}, selected["a"])
+}
+
+func TestGormPageGetterSQLite(t *testing.T) {
+	db := openCommentsSQLite(t)
+
+	ascending, errs := GormPageGetter[string, int](db, "post_id", getCommentPostId, "id", false, 2)([]Page[string, int]{
+		{Key: "a"},
+		{Key: "a", After: 2},
+		{Key: "a", After: 5},
+		{Key: "b"},
+		{Key: "b", After: 8},
+		{Key: "c"},
+	})
+	assert.Empty(t, errs)
+	assert.Equal(t, []int{1, 2}, commentIds(ascending[Page[string, int]{Key: "a"}]))
+	assert.Equal(t, []int{4, 5}, commentIds(ascending[Page[string, int]{Key: "a", After: 2}]))
+	assert.Equal(t, []int{6}, commentIds(ascending[Page[string, int]{Key: "a", After: 5}]))
+	assert.Equal(t, []int{7, 8}, commentIds(ascending[Page[string, int]{Key: "b"}]))
+	assert.Empty(t, ascending[Page[string, int]{Key: "b", After: 8}])
+	assert.Empty(t, ascending[Page[string, int]{Key: "c"}])
+	assert.Len(t, ascending, 6)
+
+	// pages are cached by their cursor as well as their key
+	loader := dataloader.NewDataLoaderWithOptions(GormPageGetter[string, int](db, "post_id", getCommentPostId, "id", true, 2), 1, 10, dataloader.Options[Page[string, int]]{Manual: true})
+	defer loader.Close()
+	first := loader.LoadPromise(Page[string, int]{Key: "a"})
+	second := loader.LoadPromise(Page[string, int]{Key: "a", After: 5})
+	loader.Flush()
+	comments, err := first.Await()
+	assert.NoError(t, err)
+	assert.Equal(t, []int{6, 5}, commentIds(comments))
+	comments, err = second.Await()
+	assert.NoError(t, err)
+	assert.Equal(t, []int{4, 2}, commentIds(comments))
+}
+
+func TestPageRounds(t *testing.T) {
+	rounds := pageRounds([]Page[string, int]{
+		{Key: "a"},
+		{Key: "b"},
+		{Key: "a", After: 1},
+		{Key: "a", After: 2},
+	})
+	assert.Equal(t, [][]Page[string, int]{
+		{{Key: "a"}, {Key: "b"}},
+		{{Key: "a", After: 1}},
+		{{Key: "a", After: 2}},
+	}, rounds)
+}
@@ -0,0 +1,134 @@
+package gormLoader
+
+import (
+	"github.com/preston-wagner/go-dataloader/internal/chunk"
+	"gorm.io/gorm"
+)
+
+// DefaultChunkSize is the most keys a getter puts in a single query unless WithChunkSize says otherwise
+const DefaultChunkSize = chunk.DefaultSize
+
+// Option configures the queries made by the gorm getters
+type Option func(*getterOptions)
+
+type getterOptions struct {
+	chunks        chunk.Options
+	chunkSizeSet  bool
+	postgresArray bool
+	scopes        []func(*gorm.DB) *gorm.DB
+	wheres        []condition
+	selects       []string
+	preloads      []preload
+	orders        []any
+}
+
+type condition struct {
+	query any
+	args  []any
+}
+
+type preload struct {
+	query string
+	args  []any
+}
+
+func newGetterOptions(options []Option) getterOptions {
+	opts := getterOptions{
+		chunks: chunk.DefaultOptions(),
+	}
+	for _, option := range options {
+		option(&opts)
+	}
+	if opts.postgresArray && !opts.chunkSizeSet {
+		opts.chunks.Size = 0 // an array is a single parameter however many keys it holds
+	}
+	return opts
+}
+
+// WithChunkSize sets the most keys put in a single query; larger batches are split into several queries and their results merged.
+// A size of zero or less disables chunking.
+func WithChunkSize(chunkSize int) Option {
+	return func(opts *getterOptions) {
+		opts.chunks.Size = chunkSize
+		opts.chunkSizeSet = true
+	}
+}
+
+// WithConcurrentChunks sets how many of a batch's chunks may be queried at the same time; by default they are queried one after another
+func WithConcurrentChunks(concurrentChunks int) Option {
+	return func(opts *getterOptions) {
+		opts.chunks.Concurrency = concurrentChunks
+	}
+}
+
+// WithPostgresArray makes GormGetter and GormListGetter pass their keys to Postgres as a single array parameter, with "column = ANY(?)", instead of expanding them into "column IN (?, ?, ...)".
+// The query then has the same text whatever the batch size, so prepared statements can be reused, and isn't subject to the bind parameter limit, so batches aren't chunked unless WithChunkSize is also used.
+func WithPostgresArray() Option {
+	return func(opts *getterOptions) {
+		opts.postgresArray = true
+	}
+}
+
+// WithScopes applies gorm scopes, such as tenant filters, to every query the getter makes
+func WithScopes(scopes ...func(*gorm.DB) *gorm.DB) Option {
+	return func(opts *getterOptions) {
+		opts.scopes = append(opts.scopes, scopes...)
+	}
+}
+
+// WithWhere adds a condition to every query the getter makes, as with gorm's Where
+func WithWhere(query any, args ...any) Option {
+	return func(opts *getterOptions) {
+		opts.wheres = append(opts.wheres, condition{query: query, args: args})
+	}
+}
+
+// WithSelect loads only the given columns; they must include the columns the getter's keys are read from
+func WithSelect(columns ...string) Option {
+	return func(opts *getterOptions) {
+		opts.selects = append(opts.selects, columns...)
+	}
+}
+
+// WithPreload preloads an association of the loaded rows, as with gorm's Preload
+func WithPreload(query string, args ...any) Option {
+	return func(opts *getterOptions) {
+		opts.preloads = append(opts.preloads, preload{query: query, args: args})
+	}
+}
+
+// WithOrder orders the loaded rows, as with gorm's Order; for GormListGetter, each key's slice keeps this order
+func WithOrder(value any) Option {
+	return func(opts *getterOptions) {
+		opts.orders = append(opts.orders, value)
+	}
+}
+
+// query applies the scopes, conditions, selected columns, preloads and ordering to a new query on db
+func (opts getterOptions) query(db *gorm.DB) *gorm.DB {
+	tx := opts.filter(db)
+	if len(opts.selects) > 0 {
+		tx = tx.Select(opts.selects)
+	}
+	tx = opts.preload(tx)
+	for _, order := range opts.orders {
+		tx = tx.Order(order)
+	}
+	return tx
+}
+
+func (opts getterOptions) preload(tx *gorm.DB) *gorm.DB {
+	for _, preload := range opts.preloads {
+		tx = tx.Preload(preload.query, preload.args...)
+	}
+	return tx
+}
+
+// filter applies just the scopes and conditions, for queries that don't load whole rows
+func (opts getterOptions) filter(db *gorm.DB) *gorm.DB {
+	tx := db.Scopes(opts.scopes...)
+	for _, where := range opts.wheres {
+		tx = tx.Where(where.query, where.args...)
+	}
+	return tx
+}
@@ -0,0 +1,105 @@
+package gormLoader
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type Author struct {
+	ID       string `gorm:"primaryKey"`
+	TenantID string
+	Name     string
+	Posts    []Post
+}
+
+type Post struct {
+	ID       string `gorm:"primaryKey"`
+	AuthorID string
+	Title    string
+	Rank     int
+}
+
+func getAuthorId(author Author) string {
+	return author.ID
+}
+
+func getPostAuthorId(post Post) string {
+	return post.AuthorID
+}
+
+func tenantScope(tenantID string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("tenant_id = ?", tenantID)
+	}
+}
+
+func openAuthorsSQLite(t *testing.T) *gorm.DB {
+	db := openSQLite(t, &Author{}, &Post{})
+	authors := []Author{
+		{ID: "ann", TenantID: "acme", Name: "Ann"},
+		{ID: "bob", TenantID: "acme", Name: "Bob"},
+		{ID: "cat", TenantID: "globex", Name: "Cat"},
+	}
+	if err := db.Create(&authors).Error; err != nil {
+		t.Fatal(err)
+	}
+	posts := []Post{
+		{ID: "p1", AuthorID: "ann", Title: "first", Rank: 2},
+		{ID: "p2", AuthorID: "ann", Title: "second", Rank: 3},
+		{ID: "p3", AuthorID: "ann", Title: "third", Rank: 1},
+		{ID: "p4", AuthorID: "bob", Title: "only", Rank: 1},
+	}
+	if err := db.Create(&posts).Error; err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestGetterOptionsSQLite(t *testing.T) {
+	db := openAuthorsSQLite(t)
+	keys := []string{"ann", "bob", "cat"}
+
+	// scopes
+	authors, errs := GormGetter(db, "id", getAuthorId, WithScopes(tenantScope("acme")))(keys)
+	assert.Empty(t, errs)
+	assert.Len(t, authors, 2)
+	assert.NotContains(t, authors, "cat")
+
+	// selected columns
+	authors, errs = GormGetter(db, "id", getAuthorId, WithSelect("id", "name"))(keys)
+	assert.Empty(t, errs)
+	assert.Equal(t, Author{ID: "ann", Name: "Ann"}, authors["ann"])
+
+	// preloads
+	authors, errs = GormGetter(db, "id", getAuthorId, WithPreload("Posts", "rank > ?", 1))(keys)
+	assert.Empty(t, errs)
+	assert.Len(t, authors["ann"].Posts, 2)
+	assert.Empty(t, authors["bob"].Posts)
+	assert.Equal(t, "Ann", authors["ann"].Name)
+
+	// ordering, which is kept within each key's slice even when the batch is chunked
+	for _, chunkSize := range []int{0, 1} {
+		posts, errs := GormListGetter(db, "author_id", getPostAuthorId, WithOrder("rank DESC"), WithChunkSize(chunkSize), WithConcurrentChunks(2))(keys)
+		assert.Empty(t, errs)
+		titles := []string{}
+		for _, post := range posts["ann"] {
+			titles = append(titles, post.Title)
+		}
+		assert.Equal(t, []string{"second", "first", "third"}, titles)
+		assert.Len(t, posts["bob"], 1)
+		assert.Empty(t, posts["cat"])
+	}
+
+	// composite getters take the same options
+	membershipDB := openSQLite(t, &Membership{})
+	if err := membershipDB.Create(&memberships).Error; err != nil {
+		t.Fatal(err)
+	}
+	admins, adminErrs := GormCompositeGetter(membershipDB, []string{"org_id", "user_id"}, getMembershipKey, WithScopes(func(db *gorm.DB) *gorm.DB {
+		return db.Where("role = ?", "admin")
+	}))([]membershipKey{{OrgID: "acme", UserID: 1}, {OrgID: "acme", UserID: 2}})
+	assert.Empty(t, adminErrs)
+	assert.Equal(t, map[membershipKey]Membership{{OrgID: "acme", UserID: 1}: memberships[0]}, admins)
+}
@@ -0,0 +1,132 @@
+package gormLoader
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/preston-wagner/go-dataloader"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type UUIDTable struct {
+	ID   uuid.UUID `gorm:"primaryKey;type:uuid"`
+	Name string
+}
+
+func getUUID(row UUIDTable) uuid.UUID {
+	return row.ID
+}
+
+// capturedQuery is the SQL and array parameter of the last query run on a dry-run database
+type capturedQuery struct {
+	sql   string
+	array string
+}
+
+// openPostgresDryRun opens a Postgres database that never connects, recording the queries it would have run
+func openPostgresDryRun(t *testing.T) (*gorm.DB, *capturedQuery) {
+	db, err := gorm.Open(postgres.Open("host=localhost"), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	captured := &capturedQuery{}
+	err = db.Callback().Query().After("gorm:query").Register("test:capture", func(tx *gorm.DB) {
+		captured.sql = tx.Statement.SQL.String()
+		if len(tx.Statement.Vars) != 1 {
+			t.Fatal("keys were not passed as a single parameter", tx.Statement.Vars)
+		}
+		value, err := tx.Statement.Vars[0].(driver.Valuer).Value()
+		if err != nil {
+			t.Fatal(err)
+		}
+		captured.array = value.(string)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, captured
+}
+
+func TestPostgresArrayQuery(t *testing.T) {
+	db, captured := openPostgresDryRun(t)
+
+	GormGetter(db, "id", getId, WithPostgresArray())([]string{"lorem", "ipsum"})
+	assert.Equal(t, `SELECT * FROM "test_tables" WHERE id = ANY($1)`, captured.sql)
+	assert.Equal(t, `{"lorem","ipsum"}`, captured.array)
+
+	GormListGetter(db, "col1", getCol1, WithPostgresArray())([]int{7, 9})
+	assert.Equal(t, `SELECT * FROM "test_tables" WHERE col1 = ANY($1)`, captured.sql)
+	assert.Equal(t, `{7,9}`, captured.array)
+
+	id := uuid.MustParse("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	GormGetter(db, "id", getUUID, WithPostgresArray())([]uuid.UUID{id})
+	assert.Equal(t, `SELECT * FROM "uuid_tables" WHERE id = ANY($1)`, captured.sql)
+	assert.Equal(t, `{"6ba7b810-9dad-11d1-80b4-00c04fd430c8"}`, captured.array)
+}
+
+func TestPostgresArrayChunkSize(t *testing.T) {
+	assert.Equal(t, 0, newGetterOptions([]Option{WithPostgresArray()}).chunks.Size)
+	assert.Equal(t, 50, newGetterOptions([]Option{WithChunkSize(50), WithPostgresArray()}).chunks.Size)
+	assert.Equal(t, DefaultChunkSize, newGetterOptions(nil).chunks.Size)
+}
+
+func TestPostgresArrayPanicsSQLite(t *testing.T) {
+	db := openSQLite(t, &TestTable{})
+	assert.Panics(t, func() {
+		GormGetter(db, "id", getId, WithPostgresArray())
+	})
+}
+
+func TestPostgresArrayCompositePanics(t *testing.T) {
+	db, _ := openPostgresDryRun(t)
+	assert.Panics(t, func() {
+		GormCompositeGetter(db, []string{"org_id", "user_id"}, getMembershipKey, WithPostgresArray())
+	})
+	assert.NotPanics(t, func() {
+		GormCompositeGetter(db, []string{"org_id", "user_id"}, getMembershipKey)
+	})
+}
+
+func TestGormGetterPostgresArray(t *testing.T) {
+	db := openPostgres(t, &TestTable{}, &UUIDTable{})
+	rows := []TestTable{
+		{ID: "lorem", Col1: 7},
+		{ID: "dolor", Col1: 7},
+		{ID: "amet", Col1: 9},
+	}
+	if err := db.Create(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	uuidRows := []UUIDTable{
+		{ID: uuid.New(), Name: "lorem"},
+		{ID: uuid.New(), Name: "ipsum"},
+	}
+	if err := db.Create(&uuidRows).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// string keys
+	values, errs := GormGetter(db, "id", getId, WithPostgresArray())([]string{"lorem", "amet", "n/a"})
+	assert.Empty(t, errs)
+	assert.Equal(t, map[string]TestTable{"lorem": rows[0], "amet": rows[2]}, values)
+
+	// int keys
+	lists, listErrs := GormListGetter(db, "col1", getCol1, WithPostgresArray())([]int{7, 8})
+	assert.Empty(t, listErrs)
+	assert.ElementsMatch(t, rows[:2], lists[7])
+	assert.Empty(t, lists[8])
+
+	// uuid keys
+	uuidLoader := dataloader.NewDataLoader(GormGetter(db, "id", getUUID, WithPostgresArray()), 1, 10)
+	defer uuidLoader.Close()
+	for _, row := range uuidRows {
+		loaded, err := uuidLoader.Load(row.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, row, loaded)
+	}
+	_, err := uuidLoader.Load(uuid.New())
+	assert.ErrorIs(t, err, dataloader.ErrMissingResponse)
+}
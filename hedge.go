@@ -0,0 +1,165 @@
+package dataloader
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A ContextGetter is like a Getter, but should stop work and return early once its context is cancelled
+type ContextGetter[KEY_TYPE comparable, VALUE_TYPE any] func(ctx context.Context, keys []KEY_TYPE) (map[KEY_TYPE]VALUE_TYPE, map[KEY_TYPE]error)
+
+// HedgePolicy configures HedgedGetter; its zero value hedges the slowest 5% of calls, while hedging no more than 10% of calls overall
+type HedgePolicy struct {
+	// Percentile of recent call latencies (between 0 and 1) after which an unfinished call is hedged; defaults to 0.95
+	Percentile float64
+	// MinDelay is the shortest a call may run before being hedged, however fast recent calls were
+	MinDelay time.Duration
+	// MaxHedgeRatio caps the fraction (between 0 and 1) of recent calls that may be hedged, so that a slow backend doesn't receive twice the load; defaults to 0.1
+	MaxHedgeRatio float64
+	// Window is how many recent calls the percentile and hedge ratio are computed over; defaults to 100.
+	// No calls are hedged until a tenth of the window has been observed.
+	Window int
+	// Clock times calls and hedging delays; defaults to SystemClock
+	Clock Clock
+}
+
+// HedgedGetter calls primary, and if it hasn't returned after the policy's percentile-based delay, calls alternate (or primary again, if alternate is nil) with the same keys.
+// The first response is returned, and the other call's context is cancelled.
+func HedgedGetter[KEY_TYPE comparable, VALUE_TYPE any](primary, alternate ContextGetter[KEY_TYPE, VALUE_TYPE], policy HedgePolicy) Getter[KEY_TYPE, VALUE_TYPE] {
+	if alternate == nil {
+		alternate = primary
+	}
+	hedger := newHedger(policy)
+	clock := clockOrDefault(policy.Clock)
+	return func(keys []KEY_TYPE) (map[KEY_TYPE]VALUE_TYPE, map[KEY_TYPE]error) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel() // cancels whichever call is still running
+
+		type response struct {
+			values map[KEY_TYPE]VALUE_TYPE
+			errs   map[KEY_TYPE]error
+		}
+		responses := make(chan response, 2)
+		call := func(getter ContextGetter[KEY_TYPE, VALUE_TYPE]) {
+			defer func() {
+				// the getter runs on its own goroutine, where a panic can't be recovered by the QueryBatcher
+				if r := recover(); r != nil {
+					responses <- response{errs: ErrForAll(keys, GetterPanicError{recovered: r})}
+				}
+			}()
+			values, errs := getter(ctx, keys)
+			responses <- response{values: values, errs: errs}
+		}
+
+		start := clock.Now()
+		go call(primary)
+		hedged := false
+		if delay, ok := hedger.delay(); ok {
+			timer := clock.NewTimer(delay)
+			defer timer.Stop()
+			select {
+			case first := <-responses:
+				hedger.observe(clock.Now().Sub(start), false)
+				return first.values, first.errs
+			case <-timer.C():
+			}
+			hedged = hedger.reserve()
+			if hedged {
+				go call(alternate)
+			}
+		}
+		first := <-responses
+		hedger.observe(clock.Now().Sub(start), hedged)
+		return first.values, first.errs
+	}
+}
+
+// hedger tracks recent calls made by a HedgedGetter, which may be shared by concurrent batches
+type hedger struct {
+	lock     *sync.Mutex
+	policy   HedgePolicy
+	samples  []hedgeSample // a ring buffer of the most recent calls
+	next     int
+	reserved int // hedges started by calls that haven't been observed yet
+}
+
+type hedgeSample struct {
+	latency time.Duration // how long the call took to get its first response
+	hedged  bool
+}
+
+func newHedger(policy HedgePolicy) *hedger {
+	if policy.Percentile <= 0 || policy.Percentile > 1 {
+		policy.Percentile = 0.95
+	}
+	if policy.MaxHedgeRatio <= 0 {
+		policy.MaxHedgeRatio = 0.1
+	}
+	if policy.Window <= 0 {
+		policy.Window = 100
+	}
+	return &hedger{
+		lock:    &sync.Mutex{},
+		policy:  policy,
+		samples: make([]hedgeSample, 0, policy.Window),
+	}
+}
+
+// delay returns how long a call should run before it is hedged, or false if too few calls have been observed to tell
+func (hdgr *hedger) delay() (time.Duration, bool) {
+	hdgr.lock.Lock()
+	defer hdgr.lock.Unlock()
+	if len(hdgr.samples) == 0 || len(hdgr.samples) < hdgr.policy.Window/10 {
+		return 0, false
+	}
+	latencies := make([]time.Duration, len(hdgr.samples))
+	for i, sample := range hdgr.samples {
+		latencies[i] = sample.latency
+	}
+	sort.Slice(latencies, func(i, j int) bool {
+		return latencies[i] < latencies[j]
+	})
+	index := int(math.Ceil(hdgr.policy.Percentile*float64(len(latencies)))) - 1
+	if index < 0 {
+		index = 0
+	}
+	if latencies[index] < hdgr.policy.MinDelay {
+		return hdgr.policy.MinDelay, true
+	}
+	return latencies[index], true
+}
+
+// reserve reports whether another hedge may be sent without exceeding MaxHedgeRatio, and if so counts it until the call is observed
+func (hdgr *hedger) reserve() bool {
+	hdgr.lock.Lock()
+	defer hdgr.lock.Unlock()
+	hedges := hdgr.reserved
+	for _, sample := range hdgr.samples {
+		if sample.hedged {
+			hedges++
+		}
+	}
+	if float64(hedges+1) > hdgr.policy.MaxHedgeRatio*float64(len(hdgr.samples)+1) {
+		return false
+	}
+	hdgr.reserved++
+	return true
+}
+
+func (hdgr *hedger) observe(latency time.Duration, hedged bool) {
+	hdgr.lock.Lock()
+	defer hdgr.lock.Unlock()
+	if hedged {
+		hdgr.reserved--
+	}
+	sample := hedgeSample{latency: latency, hedged: hedged}
+	if len(hdgr.samples) < hdgr.policy.Window {
+		hdgr.samples = append(hdgr.samples, sample)
+	} else {
+		hdgr.samples[hdgr.next] = sample
+		hdgr.next = (hdgr.next + 1) % hdgr.policy.Window
+	}
+}
@@ -0,0 +1,69 @@
+package dataloader
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// slowReplica answers immediately until slow is set, then takes a second unless its context is cancelled first
+type slowReplica struct {
+	lock *sync.Mutex
+	slow bool
+}
+
+func (replica *slowReplica) get(ctx context.Context, keys []int) (map[int]int, map[int]error) {
+	replica.lock.Lock()
+	slow := replica.slow
+	replica.lock.Unlock()
+	if slow {
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+			return nil, ErrForAll(keys, ctx.Err())
+		}
+	}
+	result := map[int]int{}
+	for _, key := range keys {
+		result[key] = key
+	}
+	return result, nil
+}
+
+func TestHedgedGetterMaxRatio(t *testing.T) {
+	replica := &slowReplica{lock: &sync.Mutex{}}
+	hedges := 0
+	lock := &sync.Mutex{}
+	alternate := func(ctx context.Context, keys []int) (map[int]int, map[int]error) {
+		lock.Lock()
+		hedges++
+		lock.Unlock()
+		return nil, nil
+	}
+	getter := HedgedGetter(replica.get, alternate, HedgePolicy{
+		MaxHedgeRatio: 0.1,
+		Window:        20,
+	})
+	for i := 0; i < 20; i++ {
+		getter([]int{i})
+	}
+
+	// the whole backend is slow now, so hedging every call would only double its load
+	replica.lock.Lock()
+	replica.slow = true
+	replica.lock.Unlock()
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(key int) {
+			defer wg.Done()
+			getter([]int{key})
+		}(i)
+	}
+	wg.Wait()
+
+	if hedges != 2 {
+		t.Fatal("HedgedGetter did not cap hedges at MaxHedgeRatio, made", hedges)
+	}
+}
@@ -0,0 +1,89 @@
+// Package chunk splits the keys of large batches into several queries, for the getters of the gorm and sqlloader packages
+package chunk
+
+import (
+	"sync"
+
+	"github.com/preston-wagner/go-dataloader"
+	"github.com/preston-wagner/unicycle/multithread"
+)
+
+// DefaultSize is the most keys a getter puts in a single query unless its options say otherwise, which keeps queries well within the bind parameter limits of Postgres, MySQL and SQLite
+const DefaultSize = 1000
+
+// Options are the chunking settings shared by the getters of both packages
+type Options struct {
+	Size        int // the most keys put in a single query, or zero or less for a single query per batch
+	Concurrency int // how many chunks may be queried at the same time
+}
+
+// DefaultOptions queries chunks of DefaultSize keys one after another
+func DefaultOptions() Options {
+	return Options{Size: DefaultSize, Concurrency: 1}
+}
+
+// Split splits keys into consecutive chunks of at most chunkSize keys, or a single chunk if chunkSize is zero or less
+func Split[KEY_TYPE any](keys []KEY_TYPE, chunkSize int) [][]KEY_TYPE {
+	if chunkSize <= 0 || len(keys) <= chunkSize {
+		return [][]KEY_TYPE{keys}
+	}
+	chunks := make([][]KEY_TYPE, 0, (len(keys)+chunkSize-1)/chunkSize)
+	for start := 0; start < len(keys); start += chunkSize {
+		end := start + chunkSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		chunks = append(chunks, keys[start:end])
+	}
+	return chunks
+}
+
+// Query runs query once per chunk of at most opts.Size keys and merges the rows it returns.
+// A chunk's error, or a panic while querying it, is only reported for the keys in that chunk, which are returned in errs; the rest are returned in succeeded.
+// Chunks are queried one after another on the calling goroutine, unless opts.Concurrency allows more at a time.
+func Query[KEY_TYPE comparable, ROW_TYPE any](keys []KEY_TYPE, opts Options, query func([]KEY_TYPE) ([]ROW_TYPE, error)) (rows []ROW_TYPE, succeeded []KEY_TYPE, errs map[KEY_TYPE]error) {
+	chunks := Split(keys, opts.Size)
+	if len(chunks) == 1 {
+		rows, err := query(keys)
+		if err != nil {
+			return nil, nil, dataloader.ErrForAll(keys, err)
+		}
+		return rows, keys, nil
+	}
+
+	lock := sync.Mutex{}
+	merge := func(chunk []KEY_TYPE) {
+		chunkRows, err := queryChunk(chunk, query)
+		lock.Lock()
+		defer lock.Unlock()
+		if err != nil {
+			if errs == nil {
+				errs = map[KEY_TYPE]error{}
+			}
+			for _, key := range chunk {
+				errs[key] = err
+			}
+		} else {
+			rows = append(rows, chunkRows...)
+			succeeded = append(succeeded, chunk...)
+		}
+	}
+	if opts.Concurrency <= 1 {
+		for _, chunk := range chunks {
+			merge(chunk)
+		}
+	} else {
+		multithread.ForEachMultithread(chunks, merge, opts.Concurrency)
+	}
+	return rows, succeeded, errs
+}
+
+// queryChunk runs query on a single chunk, turning a panic into that chunk's error so it doesn't fail the other chunks, or escape the goroutine a concurrent chunk runs on
+func queryChunk[KEY_TYPE any, ROW_TYPE any](chunk []KEY_TYPE, query func([]KEY_TYPE) ([]ROW_TYPE, error)) (rows []ROW_TYPE, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			rows, err = nil, dataloader.NewGetterPanicError(r)
+		}
+	}()
+	return query(chunk)
+}
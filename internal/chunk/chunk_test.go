@@ -0,0 +1,53 @@
+package chunk
+
+import (
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/preston-wagner/go-dataloader"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplit(t *testing.T) {
+	keys := []int{1, 2, 3, 4, 5}
+	assert.Equal(t, [][]int{{1, 2}, {3, 4}, {5}}, Split(keys, 2))
+	assert.Equal(t, [][]int{{1, 2, 3, 4, 5}}, Split(keys, 5))
+	assert.Equal(t, [][]int{{1, 2, 3, 4, 5}}, Split(keys, 0))
+}
+
+func TestQuery(t *testing.T) {
+	errChunkFailed := errors.New("chunk failed")
+	query := func(chunk []int) ([]int, error) {
+		for _, key := range chunk {
+			switch key {
+			case 3:
+				return nil, errChunkFailed
+			case 5:
+				panic("query failed")
+			}
+		}
+		return chunk, nil
+	}
+
+	for _, concurrentChunks := range []int{1, 3} {
+		rows, succeeded, errs := Query([]int{1, 2, 3, 4, 5, 6, 7}, Options{Size: 2, Concurrency: concurrentChunks}, query)
+		sort.Ints(rows)
+		sort.Ints(succeeded)
+		assert.Equal(t, []int{1, 2, 7}, rows)
+		assert.Equal(t, []int{1, 2, 7}, succeeded)
+		assert.Len(t, errs, 4)
+		assert.ErrorIs(t, errs[3], errChunkFailed)
+		assert.ErrorIs(t, errs[4], errChunkFailed)
+		// a panic only fails its own chunk, even when it happens on a goroutine of its own
+		assert.ErrorAs(t, errs[5], &dataloader.GetterPanicError{})
+		assert.ErrorAs(t, errs[6], &dataloader.GetterPanicError{})
+	}
+
+	rows, succeeded, errs := Query([]int{1, 2}, Options{}, query)
+	assert.Equal(t, []int{1, 2}, rows)
+	assert.Equal(t, []int{1, 2}, succeeded)
+	assert.Nil(t, errs)
+	_, _, errs = Query([]int{1, 3}, Options{}, query)
+	assert.Equal(t, map[int]error{1: errChunkFailed, 3: errChunkFailed}, errs)
+}
@@ -0,0 +1,147 @@
+package dataloader
+
+// a lane holds the pending keys of a single priority, split by partition, and then by tenant in the order they were requested
+type lane[CACHE_KEY comparable] struct {
+	partitions     map[string]*partitionQueue[CACHE_KEY]
+	partitionOrder *roundRobin
+	size           int
+	skipped        int // how many batches have been dispatched from higher lanes while this one was waiting
+}
+
+// a partitionQueue holds the keys that may be batched together, since every batch passed to the getter is limited to a single partition
+type partitionQueue[CACHE_KEY comparable] struct {
+	tenants     map[string]*tenantQueue[CACHE_KEY]
+	tenantOrder *roundRobin
+	size        int
+}
+
+type tenantQueue[CACHE_KEY comparable] struct {
+	order []CACHE_KEY // may contain keys that have since been promoted to a higher lane, which are skipped
+	size  int
+}
+
+func newLane[CACHE_KEY comparable]() *lane[CACHE_KEY] {
+	return &lane[CACHE_KEY]{
+		partitions:     map[string]*partitionQueue[CACHE_KEY]{},
+		partitionOrder: &roundRobin{},
+	}
+}
+
+func (ln *lane[CACHE_KEY]) enqueue(cacheKey CACHE_KEY, partition, tenant string) {
+	partitionQueue, ok := ln.partitions[partition]
+	if !ok {
+		partitionQueue = newPartitionQueue[CACHE_KEY]()
+		ln.partitions[partition] = partitionQueue
+		ln.partitionOrder.add(partition)
+	}
+	partitionQueue.enqueue(cacheKey, tenant)
+	ln.size++
+}
+
+// demote accounts for a key that has left this lane other than through a batch: promoted to a higher lane, or abandoned by its callers
+func (ln *lane[CACHE_KEY]) demote(partition, tenant string) {
+	ln.size--
+	partitionQueue := ln.partitions[partition]
+	partitionQueue.demote(tenant)
+	if partitionQueue.size == 0 {
+		ln.removePartition(partition)
+	}
+}
+
+func (ln *lane[CACHE_KEY]) removePartition(partition string) {
+	delete(ln.partitions, partition)
+	ln.partitionOrder.remove(partition)
+}
+
+func (ln *lane[CACHE_KEY]) hasEligibleTenant(canTake func(string) bool) bool {
+	for _, partitionQueue := range ln.partitions {
+		if partitionQueue.hasEligibleTenant(canTake) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextPartition picks the partition to build the next batch from, rotating through those with eligible tenants
+func (ln *lane[CACHE_KEY]) nextPartition(canTake func(string) bool) string {
+	return ln.partitionOrder.rotation(func(partition string) bool {
+		return ln.partitions[partition].hasEligibleTenant(canTake)
+	})[0]
+}
+
+func newPartitionQueue[CACHE_KEY comparable]() *partitionQueue[CACHE_KEY] {
+	return &partitionQueue[CACHE_KEY]{
+		tenants:     map[string]*tenantQueue[CACHE_KEY]{},
+		tenantOrder: &roundRobin{},
+	}
+}
+
+func (partitionQueue *partitionQueue[CACHE_KEY]) enqueue(cacheKey CACHE_KEY, tenant string) {
+	queue, ok := partitionQueue.tenants[tenant]
+	if !ok {
+		queue = &tenantQueue[CACHE_KEY]{}
+		partitionQueue.tenants[tenant] = queue
+		partitionQueue.tenantOrder.add(tenant)
+	}
+	queue.order = append(queue.order, cacheKey)
+	queue.size++
+	partitionQueue.size++
+}
+
+func (partitionQueue *partitionQueue[CACHE_KEY]) demote(tenant string) {
+	partitionQueue.size--
+	partitionQueue.tenants[tenant].size--
+	if partitionQueue.tenants[tenant].size == 0 {
+		partitionQueue.removeTenant(tenant)
+	}
+}
+
+func (partitionQueue *partitionQueue[CACHE_KEY]) removeTenant(tenant string) {
+	delete(partitionQueue.tenants, tenant)
+	partitionQueue.tenantOrder.remove(tenant)
+}
+
+func (partitionQueue *partitionQueue[CACHE_KEY]) hasEligibleTenant(canTake func(string) bool) bool {
+	for _, tenant := range partitionQueue.tenantOrder.order {
+		if canTake(tenant) {
+			return true
+		}
+	}
+	return false
+}
+
+// roundRobin tracks a set of names in the order they were added, rotating which comes first each time it is used
+type roundRobin struct {
+	order []string
+	next  int
+}
+
+func (rr *roundRobin) add(name string) {
+	rr.order = append(rr.order, name)
+}
+
+func (rr *roundRobin) remove(name string) {
+	for i, other := range rr.order {
+		if other == name {
+			rr.order = append(rr.order[:i], rr.order[i+1:]...)
+			return
+		}
+	}
+}
+
+// rotation returns the eligible names, starting one further along than the previous rotation did
+func (rr *roundRobin) rotation(eligible func(string) bool) []string {
+	names := make([]string, 0, len(rr.order))
+	if len(rr.order) == 0 {
+		return names
+	}
+	start := rr.next % len(rr.order)
+	rr.next = start + 1
+	for i := range rr.order {
+		name := rr.order[(start+i)%len(rr.order)]
+		if eligible(name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,98 @@
+package dataloader
+
+import (
+	"context"
+
+	"github.com/preston-wagner/unicycle/defaults"
+	"github.com/preston-wagner/unicycle/promises"
+)
+
+// Loader is the interface shared by QueryBatcher, DataLoader, their keyed equivalents, and loaders derived from them with Map and Then
+type Loader[KEY_TYPE any, VALUE_TYPE any] interface {
+	Load(key KEY_TYPE) (VALUE_TYPE, error)
+	LoadPromise(key KEY_TYPE) *promises.Promise[VALUE_TYPE]
+	LoadContext(ctx context.Context, key KEY_TYPE) (VALUE_TYPE, error)
+	LoadPromiseContext(ctx context.Context, key KEY_TYPE) *promises.Promise[VALUE_TYPE]
+	Close()
+}
+
+// CachingLoader is implemented by loaders that cache their results, DataLoader and KeyedDataLoader
+type CachingLoader[KEY_TYPE any, VALUE_TYPE any] interface {
+	Loader[KEY_TYPE, VALUE_TYPE]
+	Clear(key KEY_TYPE)
+	ClearAll()
+	Prime(key KEY_TYPE, value VALUE_TYPE)
+}
+
+// StatsLoader is implemented by loaders that report Stats, which is every loader in this package other than those derived with Map and Then
+type StatsLoader[KEY_TYPE any, VALUE_TYPE any] interface {
+	Loader[KEY_TYPE, VALUE_TYPE]
+	Stats() Stats
+}
+
+var (
+	_ StatsLoader[int, int]   = &QueryBatcher[int, int]{}
+	_ StatsLoader[int, int]   = &KeyedQueryBatcher[int, int, int]{}
+	_ CachingLoader[int, int] = &DataLoader[int, int]{}
+	_ StatsLoader[int, int]   = &DataLoader[int, int]{}
+	_ CachingLoader[int, int] = &KeyedDataLoader[int, int, int]{}
+	_ StatsLoader[int, int]   = &KeyedDataLoader[int, int, int]{}
+	_ Loader[int, int]        = derivedLoader[int, int]{}
+)
+
+// derivedLoader loads each key through loadPromise, sharing the caching and batching of the loaders it is derived from.
+// Closing it does nothing, since those loaders may have other users; close them directly instead.
+type derivedLoader[KEY_TYPE any, VALUE_TYPE any] struct {
+	loadPromise func(ctx context.Context, key KEY_TYPE) *promises.Promise[VALUE_TYPE]
+}
+
+func (loader derivedLoader[KEY_TYPE, VALUE_TYPE]) Load(key KEY_TYPE) (VALUE_TYPE, error) {
+	return loader.LoadPromise(key).Await()
+}
+
+func (loader derivedLoader[KEY_TYPE, VALUE_TYPE]) LoadPromise(key KEY_TYPE) *promises.Promise[VALUE_TYPE] {
+	return loader.loadPromise(context.Background(), key)
+}
+
+func (loader derivedLoader[KEY_TYPE, VALUE_TYPE]) LoadContext(ctx context.Context, key KEY_TYPE) (VALUE_TYPE, error) {
+	return awaitContext(ctx, loader.loadPromise(ctx, key))
+}
+
+func (loader derivedLoader[KEY_TYPE, VALUE_TYPE]) LoadPromiseContext(ctx context.Context, key KEY_TYPE) *promises.Promise[VALUE_TYPE] {
+	return loader.loadPromise(ctx, key)
+}
+
+func (loader derivedLoader[KEY_TYPE, VALUE_TYPE]) Close() {}
+
+// Map returns a Loader whose values are those of the given loader transformed by mapper, such as user display names from a loader of users; errors are passed through unchanged
+func Map[KEY_TYPE any, VALUE_TYPE any, MAPPED_TYPE any](loader Loader[KEY_TYPE, VALUE_TYPE], mapper func(VALUE_TYPE) MAPPED_TYPE) Loader[KEY_TYPE, MAPPED_TYPE] {
+	return derivedLoader[KEY_TYPE, MAPPED_TYPE]{
+		loadPromise: func(ctx context.Context, key KEY_TYPE) *promises.Promise[MAPPED_TYPE] {
+			promise := loader.LoadPromiseContext(ctx, key)
+			return promises.WrapInPromise(func() (MAPPED_TYPE, error) {
+				value, err := promise.Await()
+				if err != nil {
+					return defaults.ZeroValue[MAPPED_TYPE](), err
+				}
+				return mapper(value), nil
+			})
+		},
+	}
+}
+
+// Then returns a Loader for chained lookups, such as the author of a post: each key is loaded with first, keyFunc picks the key to load with second from the result, and second's value is returned.
+// An error from either loader is returned for the original key.
+func Then[KEY_TYPE any, INTERMEDIATE_TYPE any, NEXT_KEY_TYPE any, VALUE_TYPE any](first Loader[KEY_TYPE, INTERMEDIATE_TYPE], keyFunc func(INTERMEDIATE_TYPE) NEXT_KEY_TYPE, second Loader[NEXT_KEY_TYPE, VALUE_TYPE]) Loader[KEY_TYPE, VALUE_TYPE] {
+	return derivedLoader[KEY_TYPE, VALUE_TYPE]{
+		loadPromise: func(ctx context.Context, key KEY_TYPE) *promises.Promise[VALUE_TYPE] {
+			promise := first.LoadPromiseContext(ctx, key)
+			return promises.WrapInPromise(func() (VALUE_TYPE, error) {
+				intermediate, err := promise.Await()
+				if err != nil {
+					return defaults.ZeroValue[VALUE_TYPE](), err
+				}
+				return second.LoadPromiseContext(ctx, keyFunc(intermediate)).Await()
+			})
+		},
+	}
+}
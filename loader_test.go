@@ -0,0 +1,83 @@
+package dataloader
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+type testUser struct {
+	id   int
+	name string
+}
+
+type testPost struct {
+	id       int
+	authorID int
+}
+
+var errNoSuchUser = errors.New("no such user")
+
+func TestMapAndThen(t *testing.T) {
+	var requested []int
+	lock := &sync.Mutex{}
+	userGetter := func(ids []int) (map[int]testUser, map[int]error) {
+		lock.Lock()
+		requested = append(requested, ids...)
+		lock.Unlock()
+		users := map[int]testUser{}
+		errs := map[int]error{}
+		for _, id := range ids {
+			if id < 0 {
+				errs[id] = errNoSuchUser
+			} else {
+				users[id] = testUser{id: id, name: "user " + string(rune('a'+id))}
+			}
+		}
+		return users, errs
+	}
+	postGetter := func(ids []int) (map[int]testPost, map[int]error) {
+		posts := map[int]testPost{}
+		for _, id := range ids {
+			posts[id] = testPost{id: id, authorID: id - 100}
+		}
+		return posts, nil
+	}
+
+	users := NewDataLoader(userGetter, 1, 100)
+	defer users.Close()
+	posts := NewDataLoader(postGetter, 1, 100)
+	defer posts.Close()
+
+	names := Map[int, testUser, string](users, func(user testUser) string {
+		return user.name
+	})
+	authors := Then[int, testPost, int, testUser](posts, func(post testPost) int {
+		return post.authorID
+	}, users)
+
+	if name, err := names.Load(1); err != nil || name != "user b" {
+		t.Fatal("Map did not transform the loaded value, got", name, err)
+	}
+	if author, err := authors.Load(101); err != nil || author.id != 1 {
+		t.Fatal("Then did not load the value of the chained key, got", author, err)
+	}
+	if _, err := users.Load(1); err != nil {
+		t.Fatal("DataLoader did not load a key shared with derived loaders", err)
+	}
+	if len(requested) != 1 {
+		t.Fatal("derived loaders did not share the source loader's cache, requested", requested)
+	}
+
+	if _, err := names.Load(-1); !errors.Is(err, errNoSuchUser) {
+		t.Fatal("Map did not pass through the source loader's error, got", err)
+	}
+	if _, err := authors.Load(99); !errors.Is(err, errNoSuchUser) {
+		t.Fatal("Then did not pass through the second loader's error, got", err)
+	}
+
+	names.Close() // derived loaders don't close their sources
+	if _, err := users.Load(2); err != nil {
+		t.Fatal("closing a derived loader closed its source", err)
+	}
+}
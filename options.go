@@ -0,0 +1,54 @@
+package dataloader
+
+import "context"
+
+// Options configures optional behaviour shared by all loader types; the zero value leaves every option disabled
+type Options[KEY_TYPE any] struct {
+	// Normalize canonicalizes keys before they are de-duplicated, cached, or passed to the getter, so that e.g. emails differing only in case share a single lookup.
+	// Every caller still receives the result for the normalized key; returning an error rejects the key without it reaching the getter.
+	Normalize func(KEY_TYPE) (KEY_TYPE, error)
+
+	// Validate rejects malformed keys (empty strings, zero IDs, unparseable UUIDs...) before they are batched, so they neither take up batch capacity nor come back as ErrMissingResponse.
+	// It is called after Normalize, and keys it rejects fail with an error matching ErrInvalidKey that wraps the returned reason.
+	Validate func(KEY_TYPE) error
+
+	// MaxPrioritySkips bounds how many batches of higher priority may be dispatched while keys of a lower priority are waiting, so that a steady stream of high priority keys can't starve the lower lanes.
+	// Defaults to DefaultMaxPrioritySkips.
+	MaxPrioritySkips int
+
+	// Tenant identifies the tenant a key is loaded for, from the key itself or from the context passed to LoadContext (context.Background() for Load).
+	// When set, batches are filled round-robin across the tenants with keys waiting, so that one tenant loading many keys can't delay everyone else, and Stats reports per-tenant counters.
+	Tenant func(ctx context.Context, key KEY_TYPE) string
+
+	// MaxTenantShare caps the fraction (between 0 and 1) of a batch that a single tenant's keys may fill while other tenants have keys waiting; zero leaves it uncapped
+	MaxTenantShare float64
+
+	// MaxTenantConcurrency caps how many concurrent getter calls may include a single tenant's keys; zero leaves it uncapped
+	MaxTenantConcurrency int
+
+	// PartitionFunc splits keys into partitions (such as database shards) that are never mixed in a single batch; maxBatchSize then applies to each partition separately.
+	// Use NewPartitionedQueryBatcher or NewPartitionedDataLoader for getters that need to know which partition they are querying.
+	PartitionFunc func(KEY_TYPE) string
+
+	// Cost estimates how expensive a key is to load (e.g. the number of rows it will return); keys cost 1 if it isn't set
+	Cost func(KEY_TYPE) int
+
+	// MaxBatchCost closes a batch once adding another key would push its total Cost over the limit, in addition to the maxBatchSize limit on its number of keys.
+	// A key that costs more than MaxBatchCost by itself is still loaded, alone; zero leaves batch cost unlimited.
+	MaxBatchCost int
+
+	// AdaptiveBatchSize, if set, varies the effective batch size between its MinBatchSize and maxBatchSize based on how long the getter takes to respond; Stats reports the current size
+	AdaptiveBatchSize *AdaptiveBatchSize
+
+	// AdaptiveConcurrency, if set, varies how many getter calls may run at once between its MinLimit and maxConcurrentBatches based on how their latency trends; Stats reports the current limit
+	AdaptiveConcurrency *AdaptiveConcurrency
+
+	// RateLimit, if set, caps how many getter calls and keys may be sent per second
+	RateLimit *RateLimit
+
+	// Clock is used for everything time-dependent, such as measuring getter latency and waiting for rate limits; defaults to SystemClock
+	Clock Clock
+
+	// Manual stops keys from being dispatched until Dispatch or Flush is called, so that batch jobs and tests can decide exactly which keys are batched together
+	Manual bool
+}
@@ -0,0 +1,67 @@
+package dataloader
+
+import "sync"
+
+// outstandingQueries counts the promises a QueryBatcher has handed out but not yet resolved, so that Flush can wait for them
+type outstandingQueries struct {
+	lock    *sync.Mutex
+	count   int
+	idle    chan struct{} // closed once count returns to zero
+	flushes int           // how many Flush calls are waiting, during which manual mode keeps dispatching keys as they are loaded
+}
+
+func newOutstandingQueries() *outstandingQueries {
+	idle := make(chan struct{})
+	close(idle)
+	return &outstandingQueries{
+		lock: &sync.Mutex{},
+		idle: idle,
+	}
+}
+
+func (outstanding *outstandingQueries) add() {
+	outstanding.lock.Lock()
+	defer outstanding.lock.Unlock()
+	if outstanding.count == 0 {
+		outstanding.idle = make(chan struct{})
+	}
+	outstanding.count++
+}
+
+func (outstanding *outstandingQueries) done(count int) {
+	if count == 0 {
+		return
+	}
+	outstanding.lock.Lock()
+	defer outstanding.lock.Unlock()
+	outstanding.count -= count
+	if outstanding.count == 0 {
+		close(outstanding.idle)
+	}
+}
+
+// wait returns a channel that is closed once every promise is resolved, including those added after wait was called
+func (outstanding *outstandingQueries) wait() <-chan struct{} {
+	outstanding.lock.Lock()
+	defer outstanding.lock.Unlock()
+	return outstanding.idle
+}
+
+func (outstanding *outstandingQueries) beginFlush() {
+	outstanding.lock.Lock()
+	defer outstanding.lock.Unlock()
+	outstanding.flushes++
+}
+
+func (outstanding *outstandingQueries) endFlush() {
+	outstanding.lock.Lock()
+	defer outstanding.lock.Unlock()
+	outstanding.flushes--
+}
+
+// flushing reports whether a Flush call is waiting
+func (outstanding *outstandingQueries) flushing() bool {
+	outstanding.lock.Lock()
+	defer outstanding.lock.Unlock()
+	return outstanding.flushes > 0
+}
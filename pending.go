@@ -0,0 +1,248 @@
+package dataloader
+
+import (
+	"context"
+	"math"
+)
+
+// pendingQueries holds the queries that have been received but not yet dispatched, de-duplicated by cache key and split into lanes by priority
+type pendingQueries[KEY_TYPE any, CACHE_KEY comparable, VALUE_TYPE any] struct {
+	entries         map[CACHE_KEY]*pendingEntry[KEY_TYPE, VALUE_TYPE]
+	lanes           map[Priority]*lane[CACHE_KEY]
+	tenantsInFlight map[string]int
+	options         pendingOptions
+	stats           *statsRecorder
+}
+
+type pendingEntry[KEY_TYPE any, VALUE_TYPE any] struct {
+	*batchEntry[KEY_TYPE, VALUE_TYPE]
+	priority  Priority
+	partition string
+	cost      int
+
+	// the contexts of the callers waiting for this key, which is abandoned once they are all done; nil once a caller whose context can't be done is waiting
+	contexts []context.Context
+}
+
+func (entry *pendingEntry[KEY_TYPE, VALUE_TYPE]) addContext(ctx context.Context) {
+	if ctx == nil || ctx.Done() == nil {
+		entry.contexts = nil
+	} else if entry.contexts != nil || len(entry.promises) == 0 {
+		entry.contexts = append(entry.contexts, ctx)
+	}
+}
+
+// abandoned returns the error of the last caller's context to be done, if every caller has given up waiting
+func (entry *pendingEntry[KEY_TYPE, VALUE_TYPE]) abandoned() error {
+	if entry.contexts == nil {
+		return nil
+	}
+	var err error
+	for _, ctx := range entry.contexts {
+		if err = ctx.Err(); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// the subset of Options that pendingQueries needs, which don't depend on the key type
+type pendingOptions struct {
+	maxPrioritySkips     int
+	maxTenantShare       float64
+	maxTenantConcurrency int
+	maxBatchCost         int
+}
+
+func newPendingQueries[KEY_TYPE any, CACHE_KEY comparable, VALUE_TYPE any](options pendingOptions, stats *statsRecorder) *pendingQueries[KEY_TYPE, CACHE_KEY, VALUE_TYPE] {
+	if options.maxPrioritySkips <= 0 {
+		options.maxPrioritySkips = DefaultMaxPrioritySkips
+	}
+	return &pendingQueries[KEY_TYPE, CACHE_KEY, VALUE_TYPE]{
+		entries:         map[CACHE_KEY]*pendingEntry[KEY_TYPE, VALUE_TYPE]{},
+		lanes:           map[Priority]*lane[CACHE_KEY]{},
+		tenantsInFlight: map[string]int{},
+		options:         options,
+		stats:           stats,
+	}
+}
+
+func (pending *pendingQueries[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) len() int {
+	return len(pending.entries)
+}
+
+func (pending *pendingQueries[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) add(incomingQuery query[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) {
+	entry, ok := pending.entries[incomingQuery.cacheKey]
+	if !ok {
+		entry = &pendingEntry[KEY_TYPE, VALUE_TYPE]{
+			batchEntry: &batchEntry[KEY_TYPE, VALUE_TYPE]{
+				key:    incomingQuery.key,
+				tenant: incomingQuery.tenant,
+			},
+			priority:  incomingQuery.priority,
+			partition: incomingQuery.partition,
+			cost:      incomingQuery.cost,
+		}
+		pending.entries[incomingQuery.cacheKey] = entry
+		pending.enqueue(incomingQuery.cacheKey, entry)
+		pending.stats.added(entry.tenant)
+	} else if incomingQuery.priority > entry.priority {
+		// a key already waiting at a lower priority is promoted rather than requested twice
+		pending.lanes[entry.priority].demote(entry.partition, entry.tenant)
+		entry.priority = incomingQuery.priority
+		pending.enqueue(incomingQuery.cacheKey, entry)
+	}
+	entry.addContext(incomingQuery.ctx)
+	entry.promises = append(entry.promises, incomingQuery.promise)
+}
+
+// dropAbandoned rejects and removes the keys whose callers' contexts are all done, so they don't use up getter calls nobody is waiting for.
+// It returns how many promises it rejected.
+func (pending *pendingQueries[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) dropAbandoned() int {
+	rejected := 0
+	for cacheKey, entry := range pending.entries {
+		if err := entry.abandoned(); err != nil {
+			pending.lanes[entry.priority].demote(entry.partition, entry.tenant)
+			delete(pending.entries, cacheKey)
+			pending.stats.abandoned(entry.tenant)
+			btch := batch[KEY_TYPE, CACHE_KEY, VALUE_TYPE]{cacheKey: entry.batchEntry}
+			btch.rejectAll(err)
+			rejected += btch.promiseCount()
+		}
+	}
+	return rejected
+}
+
+// rejectAll rejects every pending key with the given error, returning how many promises it rejected; the pendingQueries must not be used afterwards
+func (pending *pendingQueries[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) rejectAll(err error) int {
+	btch := batch[KEY_TYPE, CACHE_KEY, VALUE_TYPE]{}
+	for cacheKey, entry := range pending.entries {
+		btch[cacheKey] = entry.batchEntry
+	}
+	btch.rejectAll(err)
+	return btch.promiseCount()
+}
+
+func (pending *pendingQueries[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) enqueue(cacheKey CACHE_KEY, entry *pendingEntry[KEY_TYPE, VALUE_TYPE]) {
+	ln, ok := pending.lanes[entry.priority]
+	if !ok {
+		ln = newLane[CACHE_KEY]()
+		pending.lanes[entry.priority] = ln
+	}
+	ln.enqueue(cacheKey, entry.partition, entry.tenant)
+}
+
+// canTake reports whether a tenant's keys may be added to a new batch, given its concurrency limit
+func (pending *pendingQueries[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) canTake(tenant string) bool {
+	return pending.options.maxTenantConcurrency <= 0 || pending.tenantsInFlight[tenant] < pending.options.maxTenantConcurrency
+}
+
+// take removes up to maxBatchSize keys of a single partition from the lane chosen by nextLane, filling the batch round-robin across tenants in the order each requested its keys.
+// If maxBatchCost is set, keys are only added while their accumulated cost stays within it, except that the first key is always taken so that expensive keys aren't stuck forever.
+// The returned batch is empty if every tenant with pending keys is at its concurrency limit.
+func (pending *pendingQueries[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) take(maxBatchSize int) (string, batch[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) {
+	eligible := map[Priority]*lane[CACHE_KEY]{}
+	for priority, ln := range pending.lanes {
+		if ln.size == 0 {
+			delete(pending.lanes, priority)
+		} else if ln.hasEligibleTenant(pending.canTake) {
+			eligible[priority] = ln
+		}
+	}
+	btch := batch[KEY_TYPE, CACHE_KEY, VALUE_TYPE]{}
+	if len(eligible) == 0 {
+		return "", btch
+	}
+	priority := nextLane(eligible, pending.options.maxPrioritySkips)
+	ln := pending.lanes[priority]
+	partition := ln.nextPartition(pending.canTake)
+	partitionQueue := ln.partitions[partition]
+
+	tenants := partitionQueue.tenantOrder.rotation(pending.canTake)
+	maxPerTenant := maxBatchSize
+	if pending.options.maxTenantShare > 0 && len(tenants) > 1 {
+		// the share is only enforced while other tenants have keys waiting, so a lone tenant can still fill whole batches
+		maxPerTenant = int(math.Ceil(pending.options.maxTenantShare * float64(maxBatchSize)))
+	}
+	takenPerTenant := map[string]int{}
+	batchCost := 0
+	for len(btch) < maxBatchSize {
+		progressed := false
+		for _, tenant := range tenants {
+			if len(btch) >= maxBatchSize || takenPerTenant[tenant] >= maxPerTenant {
+				continue
+			}
+			cacheKey, ok := pending.peek(partitionQueue, tenant, priority)
+			if !ok {
+				continue
+			}
+			entry := pending.entries[cacheKey]
+			if pending.options.maxBatchCost > 0 && len(btch) > 0 && batchCost+entry.cost > pending.options.maxBatchCost {
+				continue // this tenant's next key doesn't fit, but another tenant's might
+			}
+			pending.pop(partitionQueue, tenant)
+			btch[cacheKey] = entry.batchEntry
+			delete(pending.entries, cacheKey)
+			batchCost += entry.cost
+			takenPerTenant[tenant]++
+			ln.size--
+			progressed = true
+		}
+		if !progressed {
+			break
+		}
+	}
+	if partitionQueue.size == 0 {
+		ln.removePartition(partition)
+	}
+
+	for tenant := range takenPerTenant {
+		pending.tenantsInFlight[tenant]++
+	}
+	pending.stats.dispatched(takenPerTenant)
+	return partition, btch
+}
+
+// peek returns the oldest key a tenant has waiting in a partition, discarding keys that have since been promoted to a higher lane
+func (pending *pendingQueries[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) peek(partitionQueue *partitionQueue[CACHE_KEY], tenant string, priority Priority) (CACHE_KEY, bool) {
+	var zero CACHE_KEY
+	queue, ok := partitionQueue.tenants[tenant]
+	if !ok {
+		return zero, false // its last key was taken earlier in the same batch
+	}
+	for len(queue.order) > 0 {
+		cacheKey := queue.order[0]
+		if entry, ok := pending.entries[cacheKey]; ok && entry.priority == priority {
+			return cacheKey, true
+		}
+		queue.order = queue.order[1:]
+	}
+	partitionQueue.removeTenant(tenant)
+	return zero, false
+}
+
+// pop removes the key last returned by peek for the same tenant
+func (pending *pendingQueries[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) pop(partitionQueue *partitionQueue[CACHE_KEY], tenant string) {
+	queue := partitionQueue.tenants[tenant]
+	queue.order = queue.order[1:]
+	queue.size--
+	partitionQueue.size--
+	if queue.size == 0 {
+		partitionQueue.removeTenant(tenant)
+	}
+}
+
+// finish releases the tenant concurrency held by a batch once its getter call has returned
+func (pending *pendingQueries[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) finish(btch batch[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) {
+	tenants := map[string]struct{}{}
+	for _, entry := range btch {
+		tenants[entry.tenant] = struct{}{}
+	}
+	for tenant := range tenants {
+		pending.tenantsInFlight[tenant]--
+		if pending.tenantsInFlight[tenant] <= 0 {
+			delete(pending.tenantsInFlight, tenant)
+		}
+	}
+	pending.stats.finished(tenants)
+}
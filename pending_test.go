@@ -0,0 +1,202 @@
+package dataloader
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/preston-wagner/unicycle/promises"
+)
+
+func addTenantPending(pending *pendingQueries[string, string, int], tenant string, count int) {
+	for i := 0; i < count; i++ {
+		key := fmt.Sprintf("%s-%d", tenant, i)
+		pending.add(query[string, string, int]{
+			key:      key,
+			cacheKey: key,
+			tenant:   tenant,
+			promise:  promises.NewPromise[int](),
+		})
+	}
+}
+
+func tenantCounts(_ string, btch batch[string, string, int]) map[string]int {
+	counts := map[string]int{}
+	for key, entry := range btch {
+		if !strings.HasPrefix(key, entry.tenant+"-") {
+			panic("batch entry has the wrong tenant")
+		}
+		counts[entry.tenant]++
+	}
+	return counts
+}
+
+func TestPendingQueriesTenantRoundRobin(t *testing.T) {
+	pending := newPendingQueries[string, string, int](pendingOptions{}, newStatsRecorder(true))
+	addTenantPending(pending, "big", 100)
+	addTenantPending(pending, "small", 2)
+	addTenantPending(pending, "tiny", 1)
+
+	counts := tenantCounts(pending.take(10))
+	if counts["small"] != 2 || counts["tiny"] != 1 || counts["big"] != 7 {
+		t.Fatal("pendingQueries did not fill the batch round-robin across tenants, got", counts)
+	}
+
+	counts = tenantCounts(pending.take(10))
+	if counts["big"] != 10 {
+		t.Fatal("pendingQueries did not let a lone tenant fill the batch, got", counts)
+	}
+}
+
+func TestPendingQueriesTenantShare(t *testing.T) {
+	pending := newPendingQueries[string, string, int](pendingOptions{maxTenantShare: 0.3}, newStatsRecorder(true))
+	addTenantPending(pending, "big", 100)
+	addTenantPending(pending, "other", 100)
+
+	counts := tenantCounts(pending.take(10))
+	if counts["big"] != 3 || counts["other"] != 3 {
+		t.Fatal("pendingQueries did not cap each tenant's share of the batch, got", counts)
+	}
+}
+
+func TestPendingQueriesTenantConcurrency(t *testing.T) {
+	stats := newStatsRecorder(true)
+	pending := newPendingQueries[string, string, int](pendingOptions{maxTenantConcurrency: 1}, stats)
+	addTenantPending(pending, "big", 100)
+
+	_, first := pending.take(10)
+	if len(first) != 10 {
+		t.Fatal("pendingQueries did not fill the first batch, got", len(first))
+	}
+	if _, second := pending.take(10); len(second) != 0 {
+		t.Fatal("pendingQueries exceeded the tenant concurrency limit, got", len(second))
+	}
+
+	addTenantPending(pending, "other", 5)
+	if counts := tenantCounts(pending.take(10)); counts["big"] != 0 || counts["other"] != 5 {
+		t.Fatal("pendingQueries did not skip a tenant at its concurrency limit, got", counts)
+	}
+
+	tenantStats := stats.snapshot().Tenants["big"]
+	if tenantStats.InFlight != 1 || tenantStats.Pending != 90 || tenantStats.Keys != 10 {
+		t.Fatal("pendingQueries did not record the expected tenant stats, got", tenantStats)
+	}
+
+	pending.finish(first)
+	if counts := tenantCounts(pending.take(10)); counts["big"] != 10 {
+		t.Fatal("pendingQueries did not release the tenant concurrency of a finished batch, got", counts)
+	}
+}
+
+type tenantContextKey struct{}
+
+func TestQueryBatcherTenantStats(t *testing.T) {
+	batcher := NewQueryBatcherWithOptions(alwaysSucceedGetter, 2, 10, Options[string]{
+		Tenant: func(ctx context.Context, key string) string {
+			tenant, _ := ctx.Value(tenantContextKey{}).(string)
+			return tenant
+		},
+	})
+	defer batcher.Close()
+
+	ctx := context.WithValue(context.Background(), tenantContextKey{}, "acme")
+	for _, key := range []string{"lorem", "ipsum", "dolor"} {
+		result, err := batcher.LoadContext(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reverseString(result) != key {
+			t.Fatal("QueryBatcher did not return the expected result for the query")
+		}
+	}
+
+	stats := batcher.Stats()
+	if stats.Keys != 3 || stats.Pending != 0 {
+		t.Fatal("QueryBatcher did not report the expected stats, got", stats)
+	}
+	if stats.Tenants["acme"].Keys != 3 {
+		t.Fatal("QueryBatcher did not report the expected tenant stats, got", stats.Tenants)
+	}
+}
+
+func TestStatsRecorderPruneTenants(t *testing.T) {
+	stats := newStatsRecorder(true)
+	stats.added("acme")
+	stats.added("acme")
+	stats.added("other")
+	stats.dispatched(map[string]int{"acme": 2})
+	stats.finished(map[string]struct{}{"acme": {}})
+	if tenantStats := stats.snapshot().Tenants["acme"]; tenantStats.Batches != 1 || tenantStats.Keys != 2 {
+		t.Fatal("statsRecorder did not keep the counters of an idle tenant, got", tenantStats)
+	}
+
+	stats.pruneTenants()
+	tenants := stats.snapshot().Tenants
+	if _, ok := tenants["acme"]; ok {
+		t.Fatal("statsRecorder did not prune a tenant with nothing pending or in flight")
+	}
+	if tenants["other"].Pending != 1 {
+		t.Fatal("statsRecorder pruned a tenant with keys pending, got", tenants)
+	}
+}
+
+func TestPendingQueriesPartitions(t *testing.T) {
+	pending := newPendingQueries[string, string, int](pendingOptions{}, newStatsRecorder(false))
+	for i := 0; i < 7; i++ {
+		for _, shard := range []string{"east", "west"} {
+			key := fmt.Sprintf("%s-%d", shard, i)
+			pending.add(query[string, string, int]{
+				key:       key,
+				cacheKey:  key,
+				partition: shard,
+				promise:   promises.NewPromise[int](),
+			})
+		}
+	}
+
+	sizes := map[string][]int{}
+	for pending.len() > 0 {
+		partition, btch := pending.take(5)
+		for key := range btch {
+			if !strings.HasPrefix(key, partition+"-") {
+				t.Fatal("pendingQueries mixed partitions in a single batch:", partition, btch)
+			}
+		}
+		sizes[partition] = append(sizes[partition], len(btch))
+	}
+	for _, shard := range []string{"east", "west"} {
+		if len(sizes[shard]) != 2 || sizes[shard][0] != 5 || sizes[shard][1] != 2 {
+			t.Fatal("pendingQueries did not apply maxBatchSize to each partition separately, got", sizes)
+		}
+	}
+}
+
+func TestPendingQueriesCost(t *testing.T) {
+	pending := newPendingQueries[int, int, int](pendingOptions{maxBatchCost: 8}, newStatsRecorder(false))
+	costs := []int{5, 3, 4, 1, 10, 1, 1, 1, 1}
+	for i, cost := range costs {
+		pending.add(query[int, int, int]{
+			key:      i,
+			cacheKey: i,
+			cost:     cost,
+			promise:  promises.NewPromise[int](),
+		})
+	}
+
+	expected := [][]int{{0, 1}, {2, 3}, {4}, {5, 6, 7}, {8}}
+	for _, keys := range expected {
+		_, btch := pending.take(3)
+		if len(btch) != len(keys) {
+			t.Fatal("pendingQueries did not close the batch at the cost or size limit, expected", keys, "got", btch)
+		}
+		for _, key := range keys {
+			if _, ok := btch[key]; !ok {
+				t.Fatal("pendingQueries did not batch keys in order, expected", keys, "got", btch)
+			}
+		}
+	}
+	if pending.len() != 0 {
+		t.Fatal("pendingQueries did not return every key")
+	}
+}
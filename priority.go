@@ -0,0 +1,35 @@
+package dataloader
+
+// Priority orders pending keys: when a getter call becomes available, the pending keys with the highest priority are batched first
+type Priority int
+
+const (
+	PriorityLow    Priority = -1
+	PriorityNormal Priority = 0 // the priority of keys passed to Load and LoadPromise
+	PriorityHigh   Priority = 1
+)
+
+// DefaultMaxPrioritySkips is used when Options.MaxPrioritySkips is left at zero
+const DefaultMaxPrioritySkips = 3
+
+// nextLane picks the lane to build the next batch from: the highest priority lane, unless a lower one has been passed over too many times
+func nextLane[CACHE_KEY comparable](lanes map[Priority]*lane[CACHE_KEY], maxSkips int) Priority {
+	chosen := Priority(0)
+	found := false
+	starving := false
+	for priority, ln := range lanes {
+		lnStarving := ln.skipped >= maxSkips
+		if !found || (lnStarving && !starving) || (lnStarving == starving && priority > chosen) {
+			chosen = priority
+			found = true
+			starving = lnStarving
+		}
+	}
+	for priority, ln := range lanes {
+		if priority < chosen {
+			ln.skipped++
+		}
+	}
+	lanes[chosen].skipped = 0
+	return chosen
+}
@@ -0,0 +1,110 @@
+package dataloader
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/preston-wagner/unicycle/promises"
+)
+
+func addPending(pending *pendingQueries[int, int, int], key int, priority Priority) {
+	pending.add(query[int, int, int]{
+		key:      key,
+		cacheKey: key,
+		priority: priority,
+		promise:  promises.NewPromise[int](),
+	})
+}
+
+func TestPendingQueriesPriority(t *testing.T) {
+	pending := newPendingQueries[int, int, int](pendingOptions{maxPrioritySkips: 2}, newStatsRecorder(false))
+	for i := 0; i < 10; i++ {
+		addPending(pending, i, PriorityLow)
+	}
+	for i := 10; i < 20; i++ {
+		addPending(pending, i, PriorityHigh)
+	}
+	addPending(pending, 0, PriorityHigh) // promotes key 0 instead of requesting it twice
+
+	isHigh := func(key int) bool {
+		return key == 0 || key >= 10
+	}
+	order := []Priority{}
+	keysCount := 0
+	for pending.len() > 0 {
+		_, btch := pending.take(3)
+		priority := PriorityLow
+		for key := range btch {
+			if isHigh(key) {
+				priority = PriorityHigh
+			}
+		}
+		for key := range btch {
+			if isHigh(key) != (priority == PriorityHigh) {
+				t.Fatal("pendingQueries mixed priorities in a single batch:", btch)
+			}
+		}
+		keysCount += len(btch)
+		order = append(order, priority)
+	}
+	if keysCount != 20 {
+		t.Fatal("pendingQueries did not return every key exactly once, returned", keysCount)
+	}
+	// the high lane is served first, except that the low lane may only be passed over twice in a row
+	expected := []Priority{PriorityHigh, PriorityHigh, PriorityLow, PriorityHigh, PriorityHigh, PriorityLow, PriorityLow}
+	if len(order) != len(expected) {
+		t.Fatal("pendingQueries did not dispatch lanes in the expected order, got", order)
+	}
+	for i, priority := range expected {
+		if order[i] != priority {
+			t.Fatal("pendingQueries did not dispatch lanes in the expected order, got", order)
+		}
+	}
+}
+
+func TestQueryBatcherPriority(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var received [][]int
+	lock := &sync.Mutex{}
+	getter := func(keys []int) (map[int]int, map[int]error) {
+		lock.Lock()
+		first := len(received) == 0
+		received = append(received, keys)
+		lock.Unlock()
+		if first {
+			close(started)
+			<-release
+		}
+		result := map[int]int{}
+		for _, key := range keys {
+			result[key] = -key
+		}
+		return result, nil
+	}
+
+	batcher := NewQueryBatcher(getter, 1, 5)
+	defer batcher.Close()
+
+	blocker := batcher.LoadPromise(0)
+	<-started // the first batch occupies the only slot until released
+	loads := []*promises.Promise[int]{blocker}
+	for i := 1; i <= 5; i++ {
+		loads = append(loads, batcher.LoadPromiseWithPriority(i, PriorityLow))
+	}
+	for i := 6; i <= 10; i++ {
+		loads = append(loads, batcher.LoadPromiseWithPriority(i, PriorityHigh))
+	}
+	// every key has been handed to the batcher by now, so the next batches are taken from all of them
+	close(release)
+	promises.AwaitAll(loads...)
+
+	if len(received) != 3 {
+		t.Fatal("QueryBatcher did not batch each priority separately, made", len(received), "calls")
+	}
+	for _, key := range received[1] {
+		if key < 6 {
+			t.Fatal("QueryBatcher did not dispatch the high priority batch first, got", received[1])
+		}
+	}
+}
@@ -2,111 +2,325 @@ package dataloader
 
 import (
 	"context"
+	"time"
 
-	"github.com/preston-wagner/unicycle/maps"
-	"github.com/preston-wagner/unicycle/multithread"
+	"github.com/preston-wagner/unicycle/defaults"
 	"github.com/preston-wagner/unicycle/promises"
 )
 
 // A getter function accepts a list of de-duplicated keys, and returns a pair of maps from keys to values (for successful lookups) and keys to errors (for unsuccessful lookups)
 type Getter[KEY_TYPE comparable, VALUE_TYPE any] func([]KEY_TYPE) (map[KEY_TYPE]VALUE_TYPE, map[KEY_TYPE]error)
 
+// A KeyedGetter is like a Getter, but for key types that can't be used as map keys; it receives the original keys and responds using the cache keys produced by the loader's KeyFunc
+type KeyedGetter[KEY_TYPE any, CACHE_KEY comparable, VALUE_TYPE any] func([]KEY_TYPE) (map[CACHE_KEY]VALUE_TYPE, map[CACHE_KEY]error)
+
+// A PartitionedGetter is like a Getter, but is called with keys of a single partition at a time (as determined by Options.PartitionFunc), and is told which partition that is
+type PartitionedGetter[KEY_TYPE comparable, VALUE_TYPE any] func(partition string, keys []KEY_TYPE) (map[KEY_TYPE]VALUE_TYPE, map[KEY_TYPE]error)
+
+// every public getter type is adapted to this one internally
+type partitionedKeyedGetter[KEY_TYPE any, CACHE_KEY comparable, VALUE_TYPE any] func(partition string, keys []KEY_TYPE) (map[CACHE_KEY]VALUE_TYPE, map[CACHE_KEY]error)
+
+func ignorePartition[KEY_TYPE any, CACHE_KEY comparable, VALUE_TYPE any](getter KeyedGetter[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) partitionedKeyedGetter[KEY_TYPE, CACHE_KEY, VALUE_TYPE] {
+	return func(_ string, keys []KEY_TYPE) (map[CACHE_KEY]VALUE_TYPE, map[CACHE_KEY]error) {
+		return getter(keys)
+	}
+}
+
+// A KeyFunc reduces a key to a comparable value used for de-duplication and caching, such as a struct of its fields or a string encoding; keys that reduce to the same cache key are treated as the same key
+type KeyFunc[KEY_TYPE any, CACHE_KEY comparable] func(KEY_TYPE) CACHE_KEY
+
 type QueryBatcher[KEY_TYPE comparable, VALUE_TYPE any] struct {
-	incoming  chan query[KEY_TYPE, VALUE_TYPE]
-	ready     chan batch[KEY_TYPE, VALUE_TYPE]
-	ctx       context.Context
-	canceller func()
+	*KeyedQueryBatcher[KEY_TYPE, KEY_TYPE, VALUE_TYPE]
 }
 
 func NewQueryBatcher[KEY_TYPE comparable, VALUE_TYPE any](getter Getter[KEY_TYPE, VALUE_TYPE], maxConcurrentBatches, maxBatchSize int) *QueryBatcher[KEY_TYPE, VALUE_TYPE] {
+	return NewQueryBatcherWithOptions(getter, maxConcurrentBatches, maxBatchSize, Options[KEY_TYPE]{})
+}
+
+func NewQueryBatcherWithOptions[KEY_TYPE comparable, VALUE_TYPE any](getter Getter[KEY_TYPE, VALUE_TYPE], maxConcurrentBatches, maxBatchSize int, options Options[KEY_TYPE]) *QueryBatcher[KEY_TYPE, VALUE_TYPE] {
+	return &QueryBatcher[KEY_TYPE, VALUE_TYPE]{
+		KeyedQueryBatcher: NewKeyedQueryBatcherWithOptions(KeyedGetter[KEY_TYPE, KEY_TYPE, VALUE_TYPE](getter), identity[KEY_TYPE], maxConcurrentBatches, maxBatchSize, options),
+	}
+}
+
+// NewPartitionedQueryBatcher creates a QueryBatcher whose batches each contain keys from a single partition, as determined by options.PartitionFunc
+func NewPartitionedQueryBatcher[KEY_TYPE comparable, VALUE_TYPE any](getter PartitionedGetter[KEY_TYPE, VALUE_TYPE], maxConcurrentBatches, maxBatchSize int, options Options[KEY_TYPE]) *QueryBatcher[KEY_TYPE, VALUE_TYPE] {
+	return &QueryBatcher[KEY_TYPE, VALUE_TYPE]{
+		KeyedQueryBatcher: newKeyedQueryBatcher(partitionedKeyedGetter[KEY_TYPE, KEY_TYPE, VALUE_TYPE](getter), identity[KEY_TYPE], maxConcurrentBatches, maxBatchSize, options),
+	}
+}
+
+func identity[KEY_TYPE any](key KEY_TYPE) KEY_TYPE {
+	return key
+}
+
+// KeyedQueryBatcher is a QueryBatcher for keys that are not comparable (slices, maps, or structs containing them) or that are more naturally de-duplicated by a derived value, such as composite keys
+type KeyedQueryBatcher[KEY_TYPE any, CACHE_KEY comparable, VALUE_TYPE any] struct {
+	keyFunc     KeyFunc[KEY_TYPE, CACHE_KEY]
+	options     Options[KEY_TYPE]
+	incoming    chan query[KEY_TYPE, CACHE_KEY, VALUE_TYPE]
+	finished    chan finishedBatch[KEY_TYPE, CACHE_KEY, VALUE_TYPE]
+	dispatch    chan struct{}
+	stats       *statsRecorder
+	outstanding *outstandingQueries
+	clock       Clock
+	ctx         context.Context
+	canceller   func()
+}
+
+func NewKeyedQueryBatcher[KEY_TYPE any, CACHE_KEY comparable, VALUE_TYPE any](getter KeyedGetter[KEY_TYPE, CACHE_KEY, VALUE_TYPE], keyFunc KeyFunc[KEY_TYPE, CACHE_KEY], maxConcurrentBatches, maxBatchSize int) *KeyedQueryBatcher[KEY_TYPE, CACHE_KEY, VALUE_TYPE] {
+	return NewKeyedQueryBatcherWithOptions(getter, keyFunc, maxConcurrentBatches, maxBatchSize, Options[KEY_TYPE]{})
+}
+
+func NewKeyedQueryBatcherWithOptions[KEY_TYPE any, CACHE_KEY comparable, VALUE_TYPE any](getter KeyedGetter[KEY_TYPE, CACHE_KEY, VALUE_TYPE], keyFunc KeyFunc[KEY_TYPE, CACHE_KEY], maxConcurrentBatches, maxBatchSize int, options Options[KEY_TYPE]) *KeyedQueryBatcher[KEY_TYPE, CACHE_KEY, VALUE_TYPE] {
+	return newKeyedQueryBatcher(ignorePartition(getter), keyFunc, maxConcurrentBatches, maxBatchSize, options)
+}
+
+func newKeyedQueryBatcher[KEY_TYPE any, CACHE_KEY comparable, VALUE_TYPE any](getter partitionedKeyedGetter[KEY_TYPE, CACHE_KEY, VALUE_TYPE], keyFunc KeyFunc[KEY_TYPE, CACHE_KEY], maxConcurrentBatches, maxBatchSize int, options Options[KEY_TYPE]) *KeyedQueryBatcher[KEY_TYPE, CACHE_KEY, VALUE_TYPE] {
 	ctx, canceller := context.WithCancel(context.Background())
-	batcher := QueryBatcher[KEY_TYPE, VALUE_TYPE]{
-		incoming:  make(chan query[KEY_TYPE, VALUE_TYPE]),
-		ready:     make(chan batch[KEY_TYPE, VALUE_TYPE]),
-		ctx:       ctx,
-		canceller: canceller,
-	}
-	go batcher.batchRequests(maxBatchSize)
-	go batcher.makeRequests(getter, maxConcurrentBatches)
+	batcher := KeyedQueryBatcher[KEY_TYPE, CACHE_KEY, VALUE_TYPE]{
+		keyFunc:     keyFunc,
+		options:     options,
+		incoming:    make(chan query[KEY_TYPE, CACHE_KEY, VALUE_TYPE]),
+		finished:    make(chan finishedBatch[KEY_TYPE, CACHE_KEY, VALUE_TYPE]),
+		dispatch:    make(chan struct{}),
+		stats:       newStatsRecorder(options.Tenant != nil),
+		outstanding: newOutstandingQueries(),
+		clock:       clockOrDefault(options.Clock),
+		ctx:         ctx,
+		canceller:   canceller,
+	}
+	concurrency := newConcurrencyLimiter(options.AdaptiveConcurrency, maxConcurrentBatches)
+	batchSize := newBatchSizeController(options.AdaptiveBatchSize, maxBatchSize)
+	batcher.stats.setLimits(concurrency.current(), batchSize.current())
+	go batcher.batchRequests(getter, concurrency, batchSize, newRateLimiter(options.RateLimit, batcher.clock.Now()))
 	return &batcher
 }
 
-func (batcher *QueryBatcher[KEY_TYPE, VALUE_TYPE]) Load(key KEY_TYPE) (VALUE_TYPE, error) {
+func (batcher *KeyedQueryBatcher[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) Load(key KEY_TYPE) (VALUE_TYPE, error) {
 	return batcher.LoadPromise(key).Await()
 }
 
-func (batcher *QueryBatcher[KEY_TYPE, VALUE_TYPE]) LoadPromise(key KEY_TYPE) *promises.Promise[VALUE_TYPE] {
-	promise := promises.NewPromise[VALUE_TYPE]()
-	go func() {
-		batcher.incoming <- query[KEY_TYPE, VALUE_TYPE]{
-			key:     key,
-			promise: promise,
+func (batcher *KeyedQueryBatcher[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) LoadPromise(key KEY_TYPE) *promises.Promise[VALUE_TYPE] {
+	return batcher.LoadPromiseContext(context.Background(), key)
+}
+
+// LoadWithPriority is like Load, but pending keys of higher priority are batched ahead of those with lower priority
+func (batcher *KeyedQueryBatcher[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) LoadWithPriority(key KEY_TYPE, priority Priority) (VALUE_TYPE, error) {
+	return batcher.LoadPromiseWithPriority(key, priority).Await()
+}
+
+func (batcher *KeyedQueryBatcher[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) LoadPromiseWithPriority(key KEY_TYPE, priority Priority) *promises.Promise[VALUE_TYPE] {
+	return batcher.LoadPromiseContext(WithPriority(context.Background(), priority), key)
+}
+
+// LoadContext is like Load, but returns the context's error if it is done before the key is loaded.
+// A key whose callers' contexts are all done before it is dispatched is dropped rather than passed to the getter.
+// The context is also passed to Options.Tenant, and its priority (see WithPriority) is used to batch the key.
+func (batcher *KeyedQueryBatcher[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) LoadContext(ctx context.Context, key KEY_TYPE) (VALUE_TYPE, error) {
+	return awaitContext(ctx, batcher.LoadPromiseContext(ctx, key))
+}
+
+func (batcher *KeyedQueryBatcher[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) LoadPromiseContext(ctx context.Context, key KEY_TYPE) *promises.Promise[VALUE_TYPE] {
+	key, cacheKey, err := batcher.prepareKey(key)
+	if err != nil {
+		return rejectedPromise[VALUE_TYPE](err)
+	}
+	return batcher.loadPromise(ctx, key, cacheKey)
+}
+
+// Stats returns a snapshot of the batcher's pending keys and getter calls
+func (batcher *KeyedQueryBatcher[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) Stats() Stats {
+	return batcher.stats.snapshot()
+}
+
+// PruneTenantStats removes the Stats.Tenants entries of tenants with no keys pending or in flight, whose counters would otherwise be kept for the life of the batcher
+func (batcher *KeyedQueryBatcher[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) PruneTenantStats() {
+	batcher.stats.pruneTenants()
+}
+
+// prepareKey applies the configured Normalize and Validate options and computes the cache key of the result
+func (batcher *KeyedQueryBatcher[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) prepareKey(key KEY_TYPE) (KEY_TYPE, CACHE_KEY, error) {
+	if batcher.options.Normalize != nil {
+		normalized, err := batcher.options.Normalize(key)
+		if err != nil {
+			return key, defaults.ZeroValue[CACHE_KEY](), InvalidKeyError{key: key, reason: err}
 		}
-	}()
+		key = normalized
+	}
+	if batcher.options.Validate != nil {
+		if err := batcher.options.Validate(key); err != nil {
+			return key, defaults.ZeroValue[CACHE_KEY](), InvalidKeyError{key: key, reason: err}
+		}
+	}
+	return key, batcher.keyFunc(key), nil
+}
+
+// loadPromise enqueues a key that has already been through prepareKey, so that callers like KeyedDataLoader don't prepare it twice
+func (batcher *KeyedQueryBatcher[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) loadPromise(ctx context.Context, key KEY_TYPE, cacheKey CACHE_KEY) *promises.Promise[VALUE_TYPE] {
+	promise := promises.NewPromise[VALUE_TYPE]()
+	incomingQuery := query[KEY_TYPE, CACHE_KEY, VALUE_TYPE]{
+		ctx:      ctx,
+		key:      key,
+		cacheKey: cacheKey,
+		priority: priorityFromContext(ctx),
+		promise:  promise,
+	}
+	if batcher.options.Tenant != nil {
+		incomingQuery.tenant = batcher.options.Tenant(ctx, key)
+	}
+	if batcher.options.PartitionFunc != nil {
+		incomingQuery.partition = batcher.options.PartitionFunc(key)
+	}
+	if batcher.options.Cost != nil {
+		incomingQuery.cost = batcher.options.Cost(key)
+	} else {
+		incomingQuery.cost = 1
+	}
+	batcher.outstanding.add()
+	// the query is handed over before returning, so that a following Dispatch or Flush includes it
+	select {
+	case batcher.incoming <- incomingQuery:
+	case <-batcher.ctx.Done():
+		batcher.outstanding.done(1)
+		promise.Resolve(defaults.ZeroValue[VALUE_TYPE](), ErrClosed)
+	}
 	return promise
 }
 
-func (batcher *QueryBatcher[KEY_TYPE, VALUE_TYPE]) batchRequests(maxBatchSize int) {
-	if maxBatchSize == 0 {
+// batchRequests owns all pending queries, and starts a getter call with the next batch whenever fewer than the concurrency limit are running.
+// It is the only goroutine that uses the pending queries, concurrency, batchSize and rateLimit, so none of them need locking.
+func (batcher *KeyedQueryBatcher[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) batchRequests(getter partitionedKeyedGetter[KEY_TYPE, CACHE_KEY, VALUE_TYPE], concurrency *concurrencyLimiter, batchSize *batchSizeController, rateLimit *rateLimiter) {
+	if batchSize.current() == 0 {
 		panic("maxBatchSize must be > 0!")
 	}
-	pendingBatch := batch[KEY_TYPE, VALUE_TYPE]{}
+	pending := newPendingQueries[KEY_TYPE, CACHE_KEY, VALUE_TYPE](pendingOptions{
+		maxPrioritySkips:     batcher.options.MaxPrioritySkips,
+		maxTenantShare:       batcher.options.MaxTenantShare,
+		maxTenantConcurrency: batcher.options.MaxTenantConcurrency,
+		maxBatchCost:         batcher.options.MaxBatchCost,
+	}, batcher.stats)
+	inFlight := 0
+	var wakeTimer Timer
+	var wake <-chan time.Time // set while the rate limit is holding back the next batch
+	draining := false         // in manual mode, set by Dispatch until every pending key has been sent; keys are also sent while a Flush is waiting
 
 	for {
-		if len(pendingBatch) == 0 {
-			// if current batch is empty, just wait on new queries
-			select {
-			case incomingQuery := <-batcher.incoming:
-				pendingBatch.addToBatch(incomingQuery)
-			case <-batcher.ctx.Done():
-				batcher.cleanup()
-				return
+		select { // this first non-blocking select makes the loop prioritize adding to the pending batches
+		case incomingQuery := <-batcher.incoming:
+			pending.add(incomingQuery)
+			continue
+		default: // makes the above read non-blocking
+		}
+
+		if pending.len() == 0 {
+			draining = false
+		}
+		if pending.len() > 0 && inFlight < concurrency.current() && (!batcher.options.Manual || draining || batcher.outstanding.flushing()) {
+			batcher.outstanding.done(pending.dropAbandoned())
+			if wakeTimer != nil {
+				wakeTimer.Stop()
+				wakeTimer, wake = nil, nil
 			}
-		} else if len(pendingBatch) < maxBatchSize {
-			// add new queries to pending or send pending to be executed as available
-			select { // this first non-blocking select makes the loop prioritize adding to the pending batch
-			case incomingQuery := <-batcher.incoming:
-				pendingBatch.addToBatch(incomingQuery)
-			default: // makes the above read non-blocking
-				select {
-				case incomingQuery := <-batcher.incoming:
-					pendingBatch.addToBatch(incomingQuery)
-				case batcher.ready <- pendingBatch:
-					pendingBatch = batch[KEY_TYPE, VALUE_TYPE]{}
-				case <-batcher.ctx.Done():
-					batcher.cleanup()
-					return
-				}
+			if delay := rateLimit.delay(batcher.clock.Now()); delay > 0 {
+				wakeTimer = batcher.clock.NewTimer(delay)
+				wake = wakeTimer.C()
+			} else if partition, btch := pending.take(rateLimit.allowed(batchSize.current())); len(btch) > 0 {
+				// the batch can still come back empty if every tenant with keys waiting is at its concurrency limit
+				rateLimit.spend(len(btch))
+				inFlight++
+				go batcher.makeRequest(getter, partition, btch)
+				continue
 			}
-		} else {
-			// if current batch is at capacity, just wait for a current query to finish before starting a new one
-			select {
-			case batcher.ready <- pendingBatch:
-				pendingBatch = batch[KEY_TYPE, VALUE_TYPE]{}
-			case <-batcher.ctx.Done():
-				batcher.cleanup()
-				return
+		}
+
+		// nothing can be sent yet, so wait for either a new query, a current batch to finish, or the rate limit to allow another batch
+		select {
+		case incomingQuery := <-batcher.incoming:
+			pending.add(incomingQuery)
+		case <-wake:
+			wakeTimer, wake = nil, nil
+		case <-batcher.dispatch:
+			draining = true
+		case finished := <-batcher.finished:
+			pending.finish(finished.batch)
+			concurrency.observe(finished.latency, inFlight, finished.panicked)
+			inFlight--
+			if !finished.panicked {
+				batchSize.observe(len(finished.batch), finished.latency)
 			}
+			batcher.stats.setLimits(concurrency.current(), batchSize.current())
+		case <-batcher.ctx.Done():
+			if wakeTimer != nil {
+				wakeTimer.Stop()
+			}
+			batcher.cleanup(pending)
+			return
 		}
 	}
 }
 
-func (batcher *QueryBatcher[KEY_TYPE, VALUE_TYPE]) makeRequests(getter Getter[KEY_TYPE, VALUE_TYPE], maxConcurrentBatches int) {
-	multithread.ChannelForEachMultithread(batcher.ready, func(btch batch[KEY_TYPE, VALUE_TYPE]) {
-		defer func() {
-			if r := recover(); r != nil {
-				btch.rejectAll(GetterPanicError{recovered: r})
-			}
-		}()
-		btch.resolveAll(getter(maps.Keys(btch)))
-	}, maxConcurrentBatches)
+type finishedBatch[KEY_TYPE any, CACHE_KEY comparable, VALUE_TYPE any] struct {
+	batch    batch[KEY_TYPE, CACHE_KEY, VALUE_TYPE]
+	latency  time.Duration // how long the getter took to respond
+	panicked bool
+}
+
+func (batcher *KeyedQueryBatcher[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) makeRequest(getter partitionedKeyedGetter[KEY_TYPE, CACHE_KEY, VALUE_TYPE], partition string, btch batch[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) {
+	finished := finishedBatch[KEY_TYPE, CACHE_KEY, VALUE_TYPE]{batch: btch}
+	defer func() {
+		batcher.outstanding.done(btch.promiseCount())
+		select {
+		case batcher.finished <- finished:
+		case <-batcher.ctx.Done():
+		}
+	}()
+	start := batcher.clock.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			finished.latency = batcher.clock.Now().Sub(start)
+			finished.panicked = true
+			btch.rejectAll(GetterPanicError{recovered: r})
+		}
+	}()
+	values, errs := getter(partition, btch.keys())
+	finished.latency = batcher.clock.Now().Sub(start)
+	btch.resolveAll(values, errs)
+}
+
+// Dispatch sends every pending key to the getter, split into batches as usual, including keys loaded before the last of those batches has been started.
+// It is only needed in manual mode (see Options.Manual), since otherwise keys are dispatched as soon as possible anyway.
+func (batcher *KeyedQueryBatcher[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) Dispatch() {
+	select {
+	case batcher.dispatch <- struct{}{}:
+	case <-batcher.ctx.Done():
+	}
+}
+
+// Flush dispatches every pending key, then blocks until every key loaded so far has been resolved (as well as any loaded while it waits), or the batcher is closed.
+// It lets tests wait for the getter to be called deterministically, rather than sleeping.
+func (batcher *KeyedQueryBatcher[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) Flush() {
+	batcher.outstanding.beginFlush()
+	defer batcher.outstanding.endFlush()
+	batcher.Dispatch() // also wakes the batching loop, which then dispatches keys as they are loaded until the Flush returns
+	select {
+	case <-batcher.outstanding.wait():
+	case <-batcher.ctx.Done():
+	}
 }
 
-func (batcher *QueryBatcher[KEY_TYPE, VALUE_TYPE]) Close() {
+// Close stops the batcher: keys still waiting to be dispatched, and any loaded afterwards, fail with ErrClosed
+func (batcher *KeyedQueryBatcher[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) Close() {
 	batcher.canceller()
 }
 
-func (batcher *QueryBatcher[KEY_TYPE, VALUE_TYPE]) cleanup() {
-	close(batcher.incoming)
-	close(batcher.ready)
+// cleanup rejects the keys that were never dispatched; getter calls already running still resolve theirs
+func (batcher *KeyedQueryBatcher[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) cleanup(pending *pendingQueries[KEY_TYPE, CACHE_KEY, VALUE_TYPE]) {
+	batcher.outstanding.done(pending.rejectAll(ErrClosed))
+}
+
+func rejectedPromise[VALUE_TYPE any](err error) *promises.Promise[VALUE_TYPE] {
+	promise := promises.NewPromise[VALUE_TYPE]()
+	promise.Resolve(defaults.ZeroValue[VALUE_TYPE](), err)
+	return promise
 }
@@ -1,9 +1,15 @@
 package dataloader
 
 import (
+	"context"
 	"errors"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/preston-wagner/unicycle/promises"
 )
 
 func reverseString(str string) string {
@@ -76,11 +82,11 @@ func TestQueryBatcherSuccessMany(t *testing.T) {
 
 	maxCalls := 30
 	for i := 1; i < maxCalls; i++ {
-		go batcher.Load(i)
+		batcher.LoadPromise(i)
 	}
 	batcher.Load(maxCalls)
 
-	time.Sleep(time.Second * 5)
+	batcher.Flush()
 
 	if calls > (maxCalls / 5) { // 6
 		t.Fatal("QueryBatcher did not batch the queries, made", calls, "calls")
@@ -94,14 +100,17 @@ func TestQueryBatcherSuccessMany(t *testing.T) {
 func TestQueryBatcherSuccessMultithread(t *testing.T) {
 	calls := 0
 	keysCount := 0
+	lock := &sync.Mutex{} // the getter runs on up to three goroutines at once
 	countCallsGetter := func(input []int) (map[int]int, map[int]error) {
+		lock.Lock()
 		calls += 1
+		keysCount += len(input)
+		lock.Unlock()
 
 		time.Sleep(time.Second)
 
 		result := map[int]int{}
 		for _, value := range input {
-			keysCount++
 			result[value] = -value
 		}
 		return result, nil
@@ -112,11 +121,11 @@ func TestQueryBatcherSuccessMultithread(t *testing.T) {
 
 	maxCalls := 50
 	for i := 1; i < maxCalls; i++ {
-		go batcher.Load(i)
+		batcher.LoadPromise(i)
 	}
 	batcher.Load(maxCalls)
 
-	time.Sleep(time.Second * 5)
+	batcher.Flush()
 
 	if calls > (maxCalls / 4) {
 		t.Fatal("QueryBatcher did not batch the queries, made", calls, "calls")
@@ -126,3 +135,320 @@ func TestQueryBatcherSuccessMultithread(t *testing.T) {
 		t.Fatal("QueryBatcher did not call the getter with all keys, used", keysCount, "keys")
 	}
 }
+
+type compositeKey struct {
+	Tenant string
+	Tags   []string
+}
+
+func compositeKeyFunc(key compositeKey) string {
+	return key.Tenant + "/" + strings.Join(key.Tags, ",")
+}
+
+func TestKeyedQueryBatcher(t *testing.T) {
+	var received [][]compositeKey
+	lock := &sync.Mutex{}
+	getter := func(keys []compositeKey) (map[string]int, map[string]error) {
+		lock.Lock()
+		received = append(received, keys)
+		lock.Unlock()
+		result := map[string]int{}
+		for _, key := range keys {
+			result[compositeKeyFunc(key)] = len(key.Tags)
+		}
+		return result, nil
+	}
+
+	batcher := NewKeyedQueryBatcher(getter, compositeKeyFunc, 1, 10)
+	defer batcher.Close()
+
+	first := batcher.LoadPromise(compositeKey{Tenant: "acme", Tags: []string{"a", "b"}})
+	second := batcher.LoadPromise(compositeKey{Tenant: "acme", Tags: []string{"a", "b"}})
+	third := batcher.LoadPromise(compositeKey{Tenant: "acme", Tags: []string{"c"}})
+
+	for _, promise := range []*promises.Promise[int]{first, second} {
+		result, err := promise.Await()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != 2 {
+			t.Fatal("KeyedQueryBatcher did not return the expected result for the query")
+		}
+	}
+	result, err := third.Await()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != 1 {
+		t.Fatal("KeyedQueryBatcher did not return the expected result for the query")
+	}
+
+	for _, keys := range received {
+		seen := map[string]bool{}
+		for _, key := range keys {
+			if key.Tenant != "acme" || len(key.Tags) == 0 {
+				t.Fatal("KeyedQueryBatcher did not pass the original key to the getter, got", key)
+			}
+			if seen[compositeKeyFunc(key)] {
+				t.Fatal("KeyedQueryBatcher did not de-duplicate keys by their cache key, got", keys)
+			}
+			seen[compositeKeyFunc(key)] = true
+		}
+	}
+}
+
+func normalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if !strings.Contains(email, "@") {
+		return "", errors.New("not an email address: " + email)
+	}
+	return email, nil
+}
+
+func TestQueryBatcherNormalize(t *testing.T) {
+	var received []string
+	lock := &sync.Mutex{}
+	getter := func(keys []string) (map[string]string, map[string]error) {
+		lock.Lock()
+		received = append(received, keys...)
+		lock.Unlock()
+		return alwaysSucceedGetter(keys)
+	}
+
+	batcher := NewQueryBatcherWithOptions(getter, 1, 10, Options[string]{Normalize: normalizeEmail})
+	defer batcher.Close()
+
+	for _, key := range []string{"Bob@Example.com", " bob@example.com", "BOB@EXAMPLE.COM"} {
+		result, err := batcher.Load(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reverseString(result) != "bob@example.com" {
+			t.Fatal("QueryBatcher did not return the result for the normalized key, got", result)
+		}
+	}
+
+	_, err := batcher.Load("bob")
+	if err == nil {
+		t.Fatal("QueryBatcher did not reject a key that failed normalization")
+	}
+
+	for _, key := range received {
+		if key != "bob@example.com" {
+			t.Fatal("QueryBatcher passed a key that was not normalized to the getter:", key)
+		}
+	}
+}
+
+var errZeroKey = errors.New("key must be positive")
+
+func TestQueryBatcherValidate(t *testing.T) {
+	var received []int
+	lock := &sync.Mutex{}
+	getter := func(keys []int) (map[int]int, map[int]error) {
+		lock.Lock()
+		received = append(received, keys...)
+		lock.Unlock()
+		result := map[int]int{}
+		for _, key := range keys {
+			result[key] = -key
+		}
+		return result, nil
+	}
+
+	batcher := NewQueryBatcherWithOptions(getter, 1, 10, Options[int]{
+		Validate: func(key int) error {
+			if key <= 0 {
+				return errZeroKey
+			}
+			return nil
+		},
+	})
+	defer batcher.Close()
+
+	_, err := batcher.Load(0)
+	if !errors.Is(err, ErrInvalidKey) {
+		t.Fatal("QueryBatcher did not reject an invalid key with ErrInvalidKey, got", err)
+	}
+	if !errors.Is(err, errZeroKey) {
+		t.Fatal("QueryBatcher did not wrap the reason a key was invalid, got", err)
+	}
+
+	result, err := batcher.Load(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != -7 {
+		t.Fatal("QueryBatcher did not return the expected result for the query")
+	}
+
+	for _, key := range received {
+		if key <= 0 {
+			t.Fatal("QueryBatcher passed an invalid key to the getter:", key)
+		}
+	}
+}
+
+func TestQueryBatcherLoadContext(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	blockingGetter := func(input []string) (map[string]string, map[string]error) {
+		<-release
+		return alwaysSucceedGetter(input)
+	}
+
+	batcher := NewQueryBatcher(blockingGetter, 1, 1)
+	defer batcher.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := batcher.LoadContext(ctx, "lorem")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("QueryBatcher did not honour the context deadline, got", err)
+	}
+}
+
+func TestPartitionedQueryBatcher(t *testing.T) {
+	shardOf := func(key int) string {
+		if key%2 == 0 {
+			return "even"
+		}
+		return "odd"
+	}
+	getter := func(partition string, keys []int) (map[int]int, map[int]error) {
+		result := map[int]int{}
+		errs := map[int]error{}
+		for _, key := range keys {
+			if shardOf(key) != partition {
+				errs[key] = errors.New("key from another partition")
+			} else {
+				result[key] = -key
+			}
+		}
+		return result, errs
+	}
+
+	batcher := NewPartitionedQueryBatcher(getter, 2, 10, Options[int]{PartitionFunc: shardOf})
+	defer batcher.Close()
+
+	loads := []*promises.Promise[int]{}
+	for i := 0; i < 30; i++ {
+		loads = append(loads, batcher.LoadPromise(i))
+	}
+	for i, result := range promises.AwaitAll(loads...) {
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+		if result.Value != -i {
+			t.Fatal("QueryBatcher did not return the expected result for the query")
+		}
+	}
+}
+
+// waitUntilQueued waits until the batching loop has taken in count keys, whether they are still pending or already dispatched, and returns the stats at that point.
+// Keys are counted as dispatched as soon as the loop takes them, so the stats show whether the loop dispatched any before it had them all.
+func waitUntilQueued(batcher interface{ Stats() Stats }, count int) Stats {
+	for {
+		if stats := batcher.Stats(); stats.Pending+stats.Keys >= count {
+			return stats
+		}
+		runtime.Gosched()
+	}
+}
+
+func TestQueryBatcherManual(t *testing.T) {
+	var received [][]int
+	lock := &sync.Mutex{}
+	getter := func(keys []int) (map[int]int, map[int]error) {
+		lock.Lock()
+		received = append(received, keys)
+		lock.Unlock()
+		result := map[int]int{}
+		for _, key := range keys {
+			result[key] = -key
+		}
+		return result, nil
+	}
+
+	batcher := NewQueryBatcherWithOptions(getter, 2, 10, Options[int]{Manual: true})
+	defer batcher.Close()
+
+	loads := []*promises.Promise[int]{}
+	for i := 0; i < 25; i++ {
+		loads = append(loads, batcher.LoadPromise(i))
+	}
+	if stats := waitUntilQueued(batcher, 25); stats.Batches != 0 {
+		t.Fatal("QueryBatcher dispatched keys in manual mode before Dispatch was called")
+	}
+
+	batcher.Flush()
+	lock.Lock()
+	if len(received) != 3 {
+		t.Fatal("QueryBatcher did not split the pending keys by maxBatchSize, made", len(received), "calls")
+	}
+	lock.Unlock()
+	for i, result := range promises.AwaitAll(loads...) {
+		if result.Err != nil || result.Value != -i {
+			t.Fatal("QueryBatcher did not resolve every key before Flush returned", result.Value, result.Err)
+		}
+	}
+
+	late := batcher.LoadPromise(100)
+	batcher.Close()
+	if _, err := late.Await(); !errors.Is(err, ErrClosed) {
+		t.Fatal("QueryBatcher did not reject a pending key when closed, got", err)
+	}
+	if _, err := batcher.Load(101); !errors.Is(err, ErrClosed) {
+		t.Fatal("QueryBatcher did not reject a key loaded after Close, got", err)
+	}
+	batcher.Flush() // returns immediately once closed
+}
+
+func TestQueryBatcherManualFlushWaitsForLaterKeys(t *testing.T) {
+	called := make(chan struct{}, 10)
+	release := make(chan struct{})
+	getter := func(keys []int) (map[int]int, map[int]error) {
+		called <- struct{}{}
+		<-release
+		result := map[int]int{}
+		for _, key := range keys {
+			result[key] = -key
+		}
+		return result, nil
+	}
+
+	batcher := NewQueryBatcherWithOptions(getter, 1, 10, Options[int]{Manual: true})
+	defer batcher.Close()
+
+	first := batcher.LoadPromise(1)
+	flushed := make(chan struct{})
+	go func() {
+		batcher.Flush()
+		close(flushed)
+	}()
+	<-called
+	second := batcher.LoadPromise(2) // loaded while Flush waits for the first batch
+	close(release)
+
+	select {
+	case <-flushed:
+	case <-time.After(time.Second):
+		t.Fatal("Flush did not return after a key was loaded while it waited")
+	}
+	if result, err := first.Await(); err != nil || result != -1 {
+		t.Fatal("QueryBatcher did not resolve the key loaded before Flush", result, err)
+	}
+	if result, err := second.Await(); err != nil || result != -2 {
+		t.Fatal("QueryBatcher did not resolve the key loaded during Flush", result, err)
+	}
+
+	// once Flush has returned, manual mode holds keys back again
+	third := batcher.LoadPromise(3)
+	if stats := waitUntilQueued(batcher, 3); stats.Batches != 2 {
+		t.Fatal("QueryBatcher dispatched a key in manual mode after Flush returned")
+	}
+	batcher.Flush()
+	if result, err := third.Await(); err != nil || result != -3 {
+		t.Fatal("QueryBatcher did not resolve the key on the next Flush", result, err)
+	}
+}
@@ -0,0 +1,104 @@
+package dataloader
+
+import (
+	"math"
+	"time"
+)
+
+// RateLimit caps how often a QueryBatcher calls its getter, for getters subject to per-second quotas; either limit may be left at zero to disable it.
+// While it waits for the limit to allow another call, keys keep accumulating in the pending batches, so throttled getters are called with fuller batches.
+type RateLimit struct {
+	BatchesPerSecond float64
+	KeysPerSecond    float64
+	BatchBurst       int // how many getter calls may be made at once after a quiet period; defaults to one second's worth
+	KeyBurst         int // how many keys may be loaded at once after a quiet period, and the most a single batch may hold; defaults to one second's worth
+}
+
+// tokenBucket holds up to burst tokens, refilled continuously at rate tokens per second
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int, now time.Time) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = int(math.Ceil(rate))
+	}
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   now,
+	}
+}
+
+func (bucket *tokenBucket) refill(now time.Time) {
+	if now.After(bucket.last) {
+		bucket.tokens = math.Min(bucket.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*bucket.rate)
+		bucket.last = now
+	}
+}
+
+// delay returns how long until the bucket holds the given number of tokens, or as many as it can hold if that is fewer
+func (bucket *tokenBucket) delay(tokens float64) time.Duration {
+	missing := math.Min(tokens, bucket.burst) - bucket.tokens
+	if missing <= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(missing / bucket.rate * float64(time.Second)))
+}
+
+// rateLimiter holds batches back to keep within a RateLimit
+type rateLimiter struct {
+	batches *tokenBucket
+	keys    *tokenBucket
+}
+
+func newRateLimiter(options *RateLimit, now time.Time) *rateLimiter {
+	if options == nil {
+		return &rateLimiter{}
+	}
+	return &rateLimiter{
+		batches: newTokenBucket(options.BatchesPerSecond, options.BatchBurst, now),
+		keys:    newTokenBucket(options.KeysPerSecond, options.KeyBurst, now),
+	}
+}
+
+// delay returns how long to wait before dispatching the next batch.
+// Since the size of a batch is only known once it has been taken, spend charges it afterwards, which can leave the key bucket in debt; the next batch then waits until the debt is repaid, so keys keep accumulating in the meantime.
+func (limiter *rateLimiter) delay(now time.Time) time.Duration {
+	delay := time.Duration(0)
+	if limiter.batches != nil {
+		limiter.batches.refill(now)
+		delay = limiter.batches.delay(1)
+	}
+	if limiter.keys != nil {
+		limiter.keys.refill(now)
+		if keysDelay := limiter.keys.delay(1); keysDelay > delay {
+			delay = keysDelay
+		}
+	}
+	return delay
+}
+
+// allowed returns how many keys the next batch may contain, so that a single batch never holds more than the key burst
+func (limiter *rateLimiter) allowed(batchSize int) int {
+	if limiter.keys != nil && int(limiter.keys.burst) < batchSize {
+		return int(limiter.keys.burst)
+	}
+	return batchSize
+}
+
+func (limiter *rateLimiter) spend(keys int) {
+	if limiter.batches != nil {
+		limiter.batches.tokens--
+	}
+	if limiter.keys != nil {
+		limiter.keys.tokens -= float64(keys)
+	}
+}
@@ -0,0 +1,132 @@
+package dataloader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/preston-wagner/unicycle/promises"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Time{}
+	keys := newRateLimiter(&RateLimit{KeysPerSecond: 10}, now)
+
+	if delay := keys.delay(now); delay != 0 {
+		t.Fatal("rateLimiter did not allow the initial burst, got", delay)
+	}
+	if keys.allowed(100) != 10 || keys.allowed(5) != 5 {
+		t.Fatal("rateLimiter did not limit the batch to the key burst, got", keys.allowed(100))
+	}
+	keys.spend(4)
+	// the batch turned out smaller than the burst, so the rest of the tokens are left for the next one
+	if delay := keys.delay(now); delay != 0 {
+		t.Fatal("rateLimiter did not leave the unspent tokens of a small batch, got", delay)
+	}
+	keys.spend(10)
+	if delay := keys.delay(now); delay != 500*time.Millisecond {
+		t.Fatal("rateLimiter did not wait for the keys of the last batch to be repaid, got", delay)
+	}
+	now = now.Add(500 * time.Millisecond)
+	if delay := keys.delay(now); delay != 0 {
+		t.Fatal("rateLimiter did not refill its tokens, got", delay)
+	}
+
+	batches := newRateLimiter(&RateLimit{BatchesPerSecond: 2}, now)
+	batches.spend(10)
+	batches.spend(0)
+	if delay := batches.delay(now); delay != 500*time.Millisecond {
+		t.Fatal("rateLimiter did not limit batches per second, got", delay)
+	}
+
+	unlimited := newRateLimiter(nil, now)
+	unlimited.spend(1000)
+	if unlimited.delay(now) != 0 || unlimited.allowed(1000) != 1000 {
+		t.Fatal("rateLimiter limited batches without a RateLimit")
+	}
+}
+
+func TestQueryBatcherRateLimit(t *testing.T) {
+	var received [][]int
+	lock := &sync.Mutex{}
+	getter := func(keys []int) (map[int]int, map[int]error) {
+		lock.Lock()
+		received = append(received, keys)
+		lock.Unlock()
+		result := map[int]int{}
+		for _, key := range keys {
+			result[key] = key
+		}
+		return result, nil
+	}
+
+	batcher := NewQueryBatcherWithOptions(getter, 10, 100, Options[int]{
+		RateLimit: &RateLimit{BatchesPerSecond: 5, BatchBurst: 1},
+	})
+	defer batcher.Close()
+
+	start := time.Now()
+	loads := []*promises.Promise[int]{}
+	for i := 0; i < 50; i++ {
+		loads = append(loads, batcher.LoadPromise(i))
+	}
+	promises.AwaitAll(loads...)
+
+	if time.Since(start) < 150*time.Millisecond {
+		t.Fatal("QueryBatcher did not wait for the rate limit, took", time.Since(start))
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if len(received) > 3 {
+		t.Fatal("QueryBatcher did not accumulate keys while rate limited, made", len(received), "calls")
+	}
+}
+
+func TestQueryBatcherRateLimitContext(t *testing.T) {
+	var received []int
+	lock := &sync.Mutex{}
+	getter := func(keys []int) (map[int]int, map[int]error) {
+		lock.Lock()
+		received = append(received, keys...)
+		lock.Unlock()
+		result := map[int]int{}
+		for _, key := range keys {
+			result[key] = key
+		}
+		return result, nil
+	}
+
+	batcher := NewQueryBatcherWithOptions(getter, 10, 100, Options[int]{
+		RateLimit: &RateLimit{BatchesPerSecond: 5, BatchBurst: 1},
+	})
+	defer batcher.Close()
+
+	if _, err := batcher.Load(0); err != nil {
+		t.Fatal("QueryBatcher did not load the first key", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	abandoned := batcher.LoadPromiseContext(ctx, 1)
+	if _, err := batcher.LoadContext(ctx, 2); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("QueryBatcher did not honour the context deadline while rate limited, got", err)
+	}
+	if _, err := abandoned.Await(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("QueryBatcher did not reject a key abandoned while rate limited, got", err)
+	}
+	if _, err := batcher.Load(3); err != nil {
+		t.Fatal("QueryBatcher did not load a key after abandoning others", err)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	for _, key := range received {
+		if key == 1 || key == 2 {
+			t.Fatal("QueryBatcher passed an abandoned key to the getter, got", received)
+		}
+	}
+	if batcher.Stats().Pending != 0 {
+		t.Fatal("QueryBatcher did not stop counting abandoned keys as pending, got", batcher.Stats().Pending)
+	}
+}
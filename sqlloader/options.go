@@ -0,0 +1,62 @@
+package sqlloader
+
+import "github.com/preston-wagner/go-dataloader/internal/chunk"
+
+// Placeholder is the style of bind parameter a database expects in queries
+type Placeholder int
+
+const (
+	QuestionPlaceholder Placeholder = iota // ?, for MySQL and SQLite
+	DollarPlaceholder                      // $1, $2, ..., for Postgres
+)
+
+// DefaultChunkSize is the most keys a getter puts in a single query unless WithChunkSize says otherwise
+const DefaultChunkSize = chunk.DefaultSize
+
+// Option configures the queries made by the getters
+type Option func(*getterOptions)
+
+type getterOptions struct {
+	placeholder Placeholder
+	chunks      chunk.Options
+	orderBy     string
+}
+
+func newGetterOptions(options []Option) getterOptions {
+	opts := getterOptions{
+		placeholder: QuestionPlaceholder,
+		chunks:      chunk.DefaultOptions(),
+	}
+	for _, option := range options {
+		option(&opts)
+	}
+	return opts
+}
+
+// WithPlaceholder sets the style of bind parameter used for keys; the default is QuestionPlaceholder
+func WithPlaceholder(placeholder Placeholder) Option {
+	return func(opts *getterOptions) {
+		opts.placeholder = placeholder
+	}
+}
+
+// WithChunkSize splits batches of more than chunkSize keys into several queries, or never splits them if chunkSize is zero or less
+func WithChunkSize(chunkSize int) Option {
+	return func(opts *getterOptions) {
+		opts.chunks.Size = chunkSize
+	}
+}
+
+// WithConcurrentChunks lets up to concurrentChunks of a batch's queries run at once, instead of one after another
+func WithConcurrentChunks(concurrentChunks int) Option {
+	return func(opts *getterOptions) {
+		opts.chunks.Concurrency = concurrentChunks
+	}
+}
+
+// WithOrder orders the loaded rows by the given ORDER BY expressions, such as "name DESC"; for ListGetter, each key's slice keeps this order
+func WithOrder(orderBy string) Option {
+	return func(opts *getterOptions) {
+		opts.orderBy = orderBy
+	}
+}
@@ -0,0 +1,101 @@
+package sqlloader
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"github.com/preston-wagner/go-dataloader"
+	"github.com/preston-wagner/go-dataloader/internal/chunk"
+	"github.com/preston-wagner/unicycle/slices"
+)
+
+// Scanner reads the columns of the current row, as *sql.Rows does
+type Scanner interface {
+	Scan(dest ...any) error
+}
+
+// Source is what a getter selects rows from: a table, or a query
+type Source struct {
+	query string
+	args  []any
+	table bool
+}
+
+// Table selects every column of a table, in the order they were defined
+func Table(name string) Source {
+	return Source{query: name, table: true}
+}
+
+// Query selects the rows of a query, such as one with joins or its own conditions, which the getter filters by key.
+// Its bind parameters must use the same placeholder style as the getter; with DollarPlaceholder, the keys are numbered after args.
+// The query is selected from as a subquery, so its own ORDER BY may be ignored; use WithOrder instead.
+func Query(query string, args ...any) Source {
+	return Source{query: query, args: args}
+}
+
+// Getter fetches individual rows by a single key column, reading each with scan
+func Getter[KEY_TYPE comparable, VALUE_TYPE any](db *sql.DB, source Source, keyColumn string, scan func(Scanner) (VALUE_TYPE, error), keyGetter func(VALUE_TYPE) KEY_TYPE, options ...Option) dataloader.Getter[KEY_TYPE, VALUE_TYPE] {
+	opts := newGetterOptions(options)
+	return func(keys []KEY_TYPE) (map[KEY_TYPE]VALUE_TYPE, map[KEY_TYPE]error) {
+		values, _, errs := chunk.Query(keys, opts.chunks, func(chunk []KEY_TYPE) ([]VALUE_TYPE, error) {
+			return queryIn(db, source, keyColumn, chunk, scan, opts)
+		})
+		return slices.KeyBy(values, keyGetter), errs
+	}
+}
+
+// like Getter, except instead of fetching individual rows for each key, selects a slice of matches
+func ListGetter[KEY_TYPE comparable, VALUE_TYPE any](db *sql.DB, source Source, keyColumn string, scan func(Scanner) (VALUE_TYPE, error), keyGetter func(VALUE_TYPE) KEY_TYPE, options ...Option) dataloader.Getter[KEY_TYPE, []VALUE_TYPE] {
+	opts := newGetterOptions(options)
+	return func(keys []KEY_TYPE) (map[KEY_TYPE][]VALUE_TYPE, map[KEY_TYPE]error) {
+		values, succeeded, errs := chunk.Query(keys, opts.chunks, func(chunk []KEY_TYPE) ([]VALUE_TYPE, error) {
+			return queryIn(db, source, keyColumn, chunk, scan, opts)
+		})
+		// keys whose chunk failed are left out, so they are rejected rather than resolved as empty
+		return dataloader.FillEmpty(succeeded, slices.GroupBy(values, keyGetter)), errs
+	}
+}
+
+func queryIn[KEY_TYPE any, VALUE_TYPE any](db *sql.DB, source Source, keyColumn string, keys []KEY_TYPE, scan func(Scanner) (VALUE_TYPE, error), opts getterOptions) ([]VALUE_TYPE, error) {
+	query, args := selectIn(source, keyColumn, keys, opts)
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	values := []VALUE_TYPE{}
+	for rows.Next() {
+		value, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+// selectIn builds a query for the rows of source whose key column is any of the keys, and its arguments
+func selectIn[KEY_TYPE any](source Source, keyColumn string, keys []KEY_TYPE, opts getterOptions) (string, []any) {
+	args := make([]any, 0, len(source.args)+len(keys))
+	args = append(args, source.args...)
+	placeholders := make([]string, len(keys))
+	for i, key := range keys {
+		args = append(args, key)
+		if opts.placeholder == DollarPlaceholder {
+			placeholders[i] = "$" + strconv.Itoa(len(args))
+		} else {
+			placeholders[i] = "?"
+		}
+	}
+
+	from := source.query
+	if !source.table {
+		from = "(" + source.query + ") AS sqlloader_source"
+	}
+	query := "SELECT * FROM " + from + " WHERE " + keyColumn + " IN (" + strings.Join(placeholders, ", ") + ")"
+	if opts.orderBy != "" {
+		query += " ORDER BY " + opts.orderBy
+	}
+	return query, args
+}
@@ -0,0 +1,148 @@
+package sqlloader
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	_ "github.com/glebarez/go-sqlite"
+	"github.com/preston-wagner/go-dataloader"
+	"github.com/preston-wagner/go-dataloader/dataloadertest"
+	"github.com/stretchr/testify/assert"
+)
+
+type user struct {
+	ID     string
+	TeamID int
+	Name   string
+}
+
+func getId(usr user) string {
+	return usr.ID
+}
+
+func getTeamId(usr user) int {
+	return usr.TeamID
+}
+
+func scanUser(row Scanner) (user, error) {
+	usr := user{}
+	err := row.Scan(&usr.ID, &usr.TeamID, &usr.Name)
+	return usr, err
+}
+
+var users = []user{
+	{ID: "ann", TeamID: 1, Name: "Ann"},
+	{ID: "bob", TeamID: 1, Name: "Bob"},
+	{ID: "cat", TeamID: 2, Name: "Cat"},
+	{ID: "dan", TeamID: 3, Name: "Dan"},
+}
+
+// openSQLite opens an in-memory SQLite database holding the test users
+func openSQLite(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1) // every connection to :memory: would otherwise get its own empty database
+	t.Cleanup(func() {
+		db.Close()
+	})
+	if _, err := db.Exec("CREATE TABLE users (id TEXT PRIMARY KEY, team_id INTEGER, name TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	for _, usr := range users {
+		if _, err := db.Exec("INSERT INTO users VALUES (?, ?, ?)", usr.ID, usr.TeamID, usr.Name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func TestSelectIn(t *testing.T) {
+	query, args := selectIn(Table("users"), "id", []string{"ann", "bob"}, newGetterOptions(nil))
+	assert.Equal(t, "SELECT * FROM users WHERE id IN (?, ?)", query)
+	assert.Equal(t, []any{"ann", "bob"}, args)
+
+	query, args = selectIn(Query("SELECT * FROM users WHERE team_id = $1", 1), "id", []string{"ann", "bob"}, newGetterOptions([]Option{WithPlaceholder(DollarPlaceholder), WithOrder("name DESC")}))
+	assert.Equal(t, "SELECT * FROM (SELECT * FROM users WHERE team_id = $1) AS sqlloader_source WHERE id IN ($2, $3) ORDER BY name DESC", query)
+	assert.Equal(t, []any{1, "ann", "bob"}, args)
+}
+
+func TestGetter(t *testing.T) {
+	db := openSQLite(t)
+	recorder := dataloadertest.NewRecorder(Getter(db, Table("users"), "id", scanUser, getId))
+	loader := dataloader.NewDataLoaderWithOptions(recorder.Get, 1, 10, dataloader.Options[string]{Manual: true})
+	defer loader.Close()
+
+	ann := loader.LoadPromise("ann")
+	cat := loader.LoadPromise("cat")
+	missing := loader.LoadPromise("n/a")
+	loader.Flush()
+
+	usr, err := ann.Await()
+	assert.NoError(t, err)
+	assert.Equal(t, users[0], usr)
+	usr, err = cat.Await()
+	assert.NoError(t, err)
+	assert.Equal(t, users[2], usr)
+	_, err = missing.Await()
+	assert.ErrorIs(t, err, dataloader.ErrMissingResponse)
+	dataloadertest.AssertMaxBatches(t, recorder, 1)
+}
+
+func TestListGetter(t *testing.T) {
+	db := openSQLite(t)
+	for _, placeholder := range []Placeholder{QuestionPlaceholder, DollarPlaceholder} {
+		query := Query("SELECT * FROM users WHERE name != ?", "Cat")
+		if placeholder == DollarPlaceholder {
+			query = Query("SELECT * FROM users WHERE name != $1", "Cat")
+		}
+		teams, errs := ListGetter(db, query, "team_id", scanUser, getTeamId, WithPlaceholder(placeholder), WithOrder("name DESC"))([]int{1, 2, 4})
+		assert.Empty(t, errs)
+		assert.Equal(t, map[int][]user{1: {users[1], users[0]}, 2: {}, 4: {}}, teams)
+	}
+}
+
+func TestGetterChunks(t *testing.T) {
+	db := openSQLite(t)
+	errBadRow := errors.New("bad row")
+	scanOrFail := func(row Scanner) (user, error) {
+		usr, err := scanUser(row)
+		if err == nil && usr.ID == "cat" {
+			err = errBadRow
+		}
+		return usr, err
+	}
+
+	for _, concurrentChunks := range []int{1, 2} {
+		values, errs := Getter(db, Table("users"), "id", scanOrFail, getId, WithChunkSize(2), WithConcurrentChunks(concurrentChunks))([]string{"ann", "bob", "cat", "dan", "eve"})
+		assert.Equal(t, users[0], values["ann"])
+		assert.Equal(t, users[1], values["bob"])
+		assert.NotContains(t, values, "eve")
+		// only the keys sharing a chunk with the failing row are rejected
+		assert.Equal(t, map[string]error{"cat": errBadRow, "dan": errBadRow}, errs)
+
+		lists, listErrs := ListGetter(db, Table("users"), "id", scanOrFail, getId, WithChunkSize(2), WithConcurrentChunks(concurrentChunks))([]string{"ann", "bob", "cat", "dan", "eve"})
+		assert.Equal(t, map[string][]user{"ann": users[:1], "bob": users[1:2], "eve": {}}, lists)
+		assert.Len(t, listErrs, 2)
+	}
+}
+
+func TestGetterChunkPanics(t *testing.T) {
+	db := openSQLite(t)
+	scanOrPanic := func(row Scanner) (user, error) {
+		usr, err := scanUser(row)
+		if err == nil && usr.ID == "bob" {
+			panic("bad row")
+		}
+		return usr, err
+	}
+
+	for _, concurrentChunks := range []int{1, 2} {
+		values, errs := Getter(db, Table("users"), "id", scanOrPanic, getId, WithChunkSize(1), WithConcurrentChunks(concurrentChunks))([]string{"ann", "bob"})
+		assert.Equal(t, map[string]user{"ann": users[0]}, values)
+		assert.Len(t, errs, 1)
+		assert.ErrorAs(t, errs["bob"], &dataloader.GetterPanicError{})
+	}
+}
@@ -0,0 +1,123 @@
+package dataloader
+
+import "sync"
+
+// Stats is a snapshot of a QueryBatcher's activity
+type Stats struct {
+	Pending  int // keys waiting to be dispatched
+	InFlight int // getter calls currently running
+	Batches  int // getter calls started since the QueryBatcher was created
+	Keys     int // keys passed to the getter since the QueryBatcher was created
+
+	ConcurrencyLimit int // the most getter calls that may run at once: maxConcurrentBatches, unless Options.AdaptiveConcurrency has reduced it
+	BatchSize        int // the most keys the next batch may contain: maxBatchSize, unless Options.AdaptiveBatchSize has reduced it
+
+	// per-tenant counters, only populated when Options.Tenant is set; tenants are kept until PruneTenantStats removes them
+	Tenants map[string]TenantStats
+}
+
+type TenantStats struct {
+	Pending  int // keys of this tenant waiting to be dispatched
+	InFlight int // running getter calls that include keys of this tenant
+	Batches  int // getter calls started that included keys of this tenant
+	Keys     int // keys of this tenant passed to the getter
+}
+
+// statsRecorder is updated by batchRequests as queries move through it, and read from any goroutine by Stats
+type statsRecorder struct {
+	lock         *sync.Mutex
+	stats        Stats
+	trackTenants bool
+}
+
+func newStatsRecorder(trackTenants bool) *statsRecorder {
+	return &statsRecorder{
+		lock:         &sync.Mutex{},
+		stats:        Stats{Tenants: map[string]TenantStats{}},
+		trackTenants: trackTenants,
+	}
+}
+
+func (recorder *statsRecorder) snapshot() Stats {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	stats := recorder.stats
+	stats.Tenants = make(map[string]TenantStats, len(recorder.stats.Tenants))
+	for tenant, tenantStats := range recorder.stats.Tenants {
+		stats.Tenants[tenant] = tenantStats
+	}
+	return stats
+}
+
+func (recorder *statsRecorder) updateTenant(tenant string, update func(*TenantStats)) {
+	if !recorder.trackTenants {
+		return
+	}
+	tenantStats := recorder.stats.Tenants[tenant]
+	update(&tenantStats)
+	recorder.stats.Tenants[tenant] = tenantStats
+}
+
+// pruneTenants removes the counters of every tenant with no keys pending or in flight
+func (recorder *statsRecorder) pruneTenants() {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	for tenant, tenantStats := range recorder.stats.Tenants {
+		if tenantStats.Pending == 0 && tenantStats.InFlight == 0 {
+			delete(recorder.stats.Tenants, tenant)
+		}
+	}
+}
+
+func (recorder *statsRecorder) added(tenant string) {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	recorder.stats.Pending++
+	recorder.updateTenant(tenant, func(tenantStats *TenantStats) {
+		tenantStats.Pending++
+	})
+}
+
+func (recorder *statsRecorder) abandoned(tenant string) {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	recorder.stats.Pending--
+	recorder.updateTenant(tenant, func(tenantStats *TenantStats) {
+		tenantStats.Pending--
+	})
+}
+
+func (recorder *statsRecorder) dispatched(keysPerTenant map[string]int) {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	recorder.stats.InFlight++
+	recorder.stats.Batches++
+	for tenant, keys := range keysPerTenant {
+		recorder.stats.Pending -= keys
+		recorder.stats.Keys += keys
+		recorder.updateTenant(tenant, func(tenantStats *TenantStats) {
+			tenantStats.Pending -= keys
+			tenantStats.InFlight++
+			tenantStats.Batches++
+			tenantStats.Keys += keys
+		})
+	}
+}
+
+func (recorder *statsRecorder) finished(tenants map[string]struct{}) {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	recorder.stats.InFlight--
+	for tenant := range tenants {
+		recorder.updateTenant(tenant, func(tenantStats *TenantStats) {
+			tenantStats.InFlight--
+		})
+	}
+}
+
+func (recorder *statsRecorder) setLimits(concurrencyLimit, batchSize int) {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	recorder.stats.ConcurrencyLimit = concurrencyLimit
+	recorder.stats.BatchSize = batchSize
+}